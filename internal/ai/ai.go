@@ -2,73 +2,134 @@ package ai
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
-	"github.com/KillAllChickens/argus/internal/helpers"
-	"github.com/KillAllChickens/argus/internal/printer"
-	"github.com/KillAllChickens/argus/internal/shared"
-	"github.com/KillAllChickens/argus/internal/vars"
-
 	"google.golang.org/genai"
 )
 
-var Limiter = NewTokenRateLimiter(1_000_000, 900_000)
+// Used when a quota error does not tell us how long to wait
+const defaultRetryDelay = 30 * time.Second
 
-func AIResponseWithRateLimit(system_prompt string, prompt string) string {
-	if !vars.AI {
-		return "true" // Include everything, including false positives
-	}
+// Rough token cost of a single image for Gemini models
+const imageTokens = 258
+
+const maxAttempts = 5
+
+// ErrEmptyResponse is returned when the model answered without any text.
+var ErrEmptyResponse = errors.New("AI returned an empty response")
 
-	Limiter.waitIfNearLimit()
+// Client is a long-lived AI client, created once per scan and shared between workers.
+type Client struct {
+	genai   *genai.Client
+	model   string
+	Limiter *RateLimiter
+}
+
+func NewClient(ctx context.Context, apiKey string, provider string, model string) (*Client, error) {
+	if provider != "gemini" {
+		return nil, fmt.Errorf("unsupported AI provider %q", provider)
+	}
 
-	ctx := context.Background()
-	// client, err := genai.NewClient(ctx, option.WithAPIKey(vars.GeminiAPIKey))
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey: vars.GeminiAPIKey,
+		APIKey:  apiKey,
+		Backend: genai.BackendGeminiAPI,
 	})
-	helpers.HandleErr(err)
-	// defer client.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not create AI client: %w", err)
+	}
 
-	config := &genai.GenerateContentConfig{
-		SystemInstruction: genai.NewContentFromText(system_prompt, genai.RoleUser),
+	return &Client{
+		genai:   client,
+		model:   model,
+		Limiter: NewRateLimiter(LimitsFor(provider, model), nil),
+	}, nil
+}
+
+// Generate sends prompt to the model with the given system prompt and returns its text answer.
+// Per minute quota errors are retried, a spent daily quota returns ErrDailyQuota.
+func (c *Client) Generate(ctx context.Context, systemPrompt string, prompt string) (string, error) {
+	return c.generate(ctx, systemPrompt, genai.Text(prompt), estimateTokens(systemPrompt, prompt))
+}
+
+// Verify asks the model a true/false question and parses the answer.
+func (c *Client) Verify(ctx context.Context, systemPrompt string, prompt string) (bool, error) {
+	answer, err := c.Generate(ctx, systemPrompt, prompt)
+	if err != nil {
+		return false, err
 	}
+	return parseVerdict(answer)
+}
 
-	parts := genai.Text(prompt)
+// VerifyWithImage is Verify for vision capable models, sending an image along with the prompt.
+func (c *Client) VerifyWithImage(ctx context.Context, systemPrompt string, prompt string, image []byte, mimeType string) (bool, error) {
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{
+			genai.NewPartFromBytes(image, mimeType),
+			genai.NewPartFromText(prompt),
+		}, genai.RoleUser),
+	}
 
-	resp, err := client.Models.GenerateContent(
-		ctx,
-		"gemini-2.0-flash-lite",
-		parts,
-		config,
-	)
-	// helpers.HandleErr(err)
+	answer, err := c.generate(ctx, systemPrompt, contents, estimateTokens(systemPrompt, prompt)+imageTokens)
 	if err != nil {
-		if strings.Contains(err.Error(), "GenerateContentInputTokensPerModelPerMinute") || strings.Contains(err.Error(), "The model is overloaded") {
-			_ = shared.Bar.Clear()
-			printer.Info("Hit AI quota limit for this minute, sleeping for 30 seconds and trying again.")
-			time.Sleep(30 * time.Second)
-			return AIResponse(system_prompt, prompt)
-		}
-		if strings.Contains(err.Error(), "GenerateContentInputTokensPerModelPerDay") {
-			_ = shared.Bar.Clear()
-			printer.Info("Hit AI quota limit for today, continuing without AI.")
-			vars.AI = false
-			return AIResponse(system_prompt, prompt)
-		}
+		return false, err
 	}
-	// if err.Error != nil { // Happens when HandleErr returns without exitting the program
-	// 	return "true"
-	// }
+	return parseVerdict(answer)
+}
 
-	if resp != nil && resp.UsageMetadata != nil {
-		Limiter.recordUsage(int(resp.UsageMetadata.TotalTokenCount))
+func (c *Client) generate(ctx context.Context, systemPrompt string, contents []*genai.Content, estimatedTokens int) (string, error) {
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(systemPrompt, genai.RoleUser),
 	}
 
-	return resp.Text()
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		reservation, err := c.Limiter.Wait(ctx, estimatedTokens)
+		if err != nil {
+			return "", err
+		}
 
+		resp, err := c.genai.Models.GenerateContent(ctx, c.model, contents, config)
+		if err != nil {
+			kind, delay := classifyError(err)
+			switch kind {
+			case quotaDay:
+				c.Limiter.ExhaustDay()
+				return "", ErrDailyQuota
+			case quotaMinute, quotaOverloaded:
+				if delay == 0 {
+					delay = defaultRetryDelay
+				}
+				c.Limiter.Backoff(delay)
+				lastErr = err
+				continue
+			default:
+				return "", fmt.Errorf("AI request failed: %w", err)
+			}
+		}
+
+		if resp.UsageMetadata != nil {
+			c.Limiter.Record(reservation, int(resp.UsageMetadata.TotalTokenCount))
+		}
+
+		text := strings.TrimSpace(resp.Text())
+		if text == "" {
+			return "", ErrEmptyResponse
+		}
+		return text, nil
+	}
+
+	return "", fmt.Errorf("AI request failed after %d attempts: %w", maxAttempts, lastErr)
 }
 
-func AIResponse(system_prompt string, prompt string) string {
-	return AIResponseWithRateLimit(system_prompt, prompt)
+func parseVerdict(answer string) (bool, error) {
+	switch strings.Trim(strings.ToLower(answer), " \n\t.\"'`") {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected AI verdict %q", answer)
 }
@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+type quotaKind int
+
+const (
+	quotaNone quotaKind = iota
+	quotaMinute
+	quotaDay
+	quotaOverloaded
+)
+
+// classifyError inspects a GenerateContent error and reports which quota (if any) was hit,
+// along with the retry delay sent by the API (0 if there was none).
+func classifyError(err error) (quotaKind, time.Duration) {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return quotaNone, 0
+	}
+
+	delay := retryDelay(apiErr)
+
+	switch apiErr.Code {
+	case http.StatusTooManyRequests:
+		if isDailyViolation(apiErr) {
+			return quotaDay, delay
+		}
+		return quotaMinute, delay
+	case http.StatusServiceUnavailable:
+		return quotaOverloaded, delay
+	}
+	return quotaNone, delay
+}
+
+// retryDelay reads google.rpc.RetryInfo out of the error details.
+func retryDelay(apiErr genai.APIError) time.Duration {
+	for _, detail := range apiErr.Details {
+		if typ, _ := detail["@type"].(string); !strings.HasSuffix(typ, "google.rpc.RetryInfo") {
+			continue
+		}
+		raw, _ := detail["retryDelay"].(string)
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d
+		}
+	}
+	return 0
+}
+
+// isDailyViolation reports whether any google.rpc.QuotaFailure violation is a per day quota.
+func isDailyViolation(apiErr genai.APIError) bool {
+	for _, detail := range apiErr.Details {
+		if typ, _ := detail["@type"].(string); !strings.HasSuffix(typ, "google.rpc.QuotaFailure") {
+			continue
+		}
+		violations, _ := detail["violations"].([]any)
+		for _, v := range violations {
+			violation, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			quotaID, _ := violation["quotaId"].(string)
+			if strings.Contains(quotaID, "PerDay") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// estimateTokens is a rough guess (~4 characters per token) used to reserve room in the token window.
+func estimateTokens(texts ...string) int {
+	total := 0
+	for _, t := range texts {
+		total += len(t)
+	}
+	return total/4 + 1
+}
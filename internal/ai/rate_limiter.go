@@ -1,92 +1,226 @@
 package ai
 
 import (
-	"github.com/KillAllChickens/argus/internal/helpers"
-	"github.com/KillAllChickens/argus/internal/shared"
-	"github.com/KillAllChickens/argus/internal/vars"
-
+	"context"
+	"errors"
 	"sync"
 	"time"
+
+	"github.com/KillAllChickens/argus/internal/vars"
 )
 
-type TokenRateLimiter struct {
-	mu                sync.Mutex
-	limit             int
-	highWaterMark     int
-	currentTokenCount int
-	windowStartTime   time.Time
+// ErrDailyQuota is returned by Wait once the requests-per-day quota is used up.
+var ErrDailyQuota = errors.New("daily AI request quota exhausted")
+
+// DefaultRateLimits are the free tier quotas, used when config.json does not override them.
+var DefaultRateLimits = map[string]map[string]vars.RateLimits{
+	"gemini": {
+		"gemini-2.0-flash-lite": {RequestsPerMinute: 30, TokensPerMinute: 1_000_000, RequestsPerDay: 200},
+		"gemini-2.0-flash":      {RequestsPerMinute: 15, TokensPerMinute: 1_000_000, RequestsPerDay: 200},
+		"gemini-2.5-flash-lite": {RequestsPerMinute: 15, TokensPerMinute: 250_000, RequestsPerDay: 1_000},
+		"gemini-2.5-flash":      {RequestsPerMinute: 10, TokensPerMinute: 250_000, RequestsPerDay: 250},
+		"gemini-2.5-pro":        {RequestsPerMinute: 5, TokensPerMinute: 250_000, RequestsPerDay: 100},
+	},
 }
 
-func NewTokenRateLimiter(limit int, highWaterMark int) *TokenRateLimiter {
-	return &TokenRateLimiter{
-		limit:             limit,
-		highWaterMark:     highWaterMark,
-		windowStartTime:   time.Now(),
-		currentTokenCount: 0,
+// LimitsFor returns the configured limits for a provider and model, falling back to DefaultRateLimits.
+func LimitsFor(provider string, model string) vars.RateLimits {
+	if limits, ok := vars.AIRateLimits[provider][model]; ok {
+		return limits
 	}
+	return DefaultRateLimits[provider][model]
 }
 
-func (l *TokenRateLimiter) waitIfNearLimit() {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+// Clock abstracts time so the limiter can be tested without sleeping.
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d, or returns ctx.Err() when ctx is done first.
+	Sleep(ctx context.Context, d time.Duration) error
+}
 
-	for {
-		now := time.Now()
-		elapsed := now.Sub(l.windowStartTime)
+type realClock struct{}
 
-		if elapsed >= (30 * time.Second) {
-			if vars.Verbose {
-				_ = shared.Bar.Clear()
-				helpers.V("Rate limit window reset.")
-			}
-			l.windowStartTime = now
-			l.currentTokenCount = 0
-			return
-		}
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-		if l.currentTokenCount >= l.highWaterMark {
+type tokenUsage struct {
+	at    time.Time
+	count int
+}
 
-			timeLeftInWindow := (30 * time.Second) - elapsed
+// Reservation holds the tokens Wait set aside for a request, until Record replaces them
+// with what the request actually used.
+type Reservation struct {
+	usage *tokenUsage
+}
 
-			sleepDuration := timeLeftInWindow + (10 * time.Second)
-			if vars.Verbose {
-				_ = shared.Bar.Clear()
-				helpers.V(
-					"Rate limit high-water mark (%d) reached. Current count: %d. Sleeping for %v...",
-					l.highWaterMark,
-					l.currentTokenCount,
-					sleepDuration,
-				)
-			}
+// RateLimiter enforces requests per minute, tokens per minute and requests per day
+// using sliding windows.
+type RateLimiter struct {
+	mu     sync.Mutex
+	limits vars.RateLimits
+	clock  Clock
+
+	minuteRequests []time.Time
+	dayRequests    []time.Time
+	tokens         []*tokenUsage // used and reserved
+	blockedUntil   time.Time
+	exhaustedUntil time.Time
+
+	// OnWait is called (without the lock held) every time the limiter is about to sleep.
+	OnWait func(wait time.Duration, reason string)
+}
+
+func NewRateLimiter(limits vars.RateLimits, clock Clock) *RateLimiter {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &RateLimiter{
+		limits: limits,
+		clock:  clock,
+	}
+}
+
+// Wait blocks until a request estimated to use estimatedTokens tokens fits in every window,
+// then reserves a request slot and the estimated tokens for it, so concurrent requests
+// can't all pass the same check. It returns ErrDailyQuota instead of waiting for the next
+// day, and ctx.Err() when ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, estimatedTokens int) (*Reservation, error) {
+	if tpm := l.limits.TokensPerMinute; tpm > 0 && estimatedTokens > tpm {
+		estimatedTokens = tpm
+	}
+
+	l.mu.Lock()
+	for {
+		if err := ctx.Err(); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+
+		now := l.clock.Now()
+		l.prune(now)
+
+		if now.Before(l.exhaustedUntil) || (l.limits.RequestsPerDay > 0 && len(l.dayRequests) >= l.limits.RequestsPerDay) {
+			l.mu.Unlock()
+			return nil, ErrDailyQuota
+		}
 
+		wait, reason := l.nextSlot(now, estimatedTokens)
+		if wait <= 0 {
+			l.minuteRequests = append(l.minuteRequests, now)
+			l.dayRequests = append(l.dayRequests, now)
+			usage := &tokenUsage{at: now, count: estimatedTokens}
+			l.tokens = append(l.tokens, usage)
 			l.mu.Unlock()
-			time.Sleep(sleepDuration)
-			l.mu.Lock()
+			return &Reservation{usage: usage}, nil
+		}
 
-			continue
+		l.mu.Unlock()
+		if l.OnWait != nil {
+			l.OnWait(wait, reason)
 		}
+		if err := l.clock.Sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		l.mu.Lock()
+	}
+}
+
+// Record replaces the tokens reserved for a finished request with the tokens it used.
+// Without a Record, the estimate stays counted.
+func (l *RateLimiter) Record(r *Reservation, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-		return
+	r.usage.count = tokens
+}
+
+// Backoff stops all requests for d, used when the API tells us how long to wait.
+func (l *RateLimiter) Backoff(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := l.clock.Now().Add(d)
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
 	}
 }
 
-func (l *TokenRateLimiter) recordUsage(tokens int) {
+// ExhaustDay marks the daily quota as used up, e.g. when the API reports it before we counted it.
+func (l *RateLimiter) ExhaustDay() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if time.Since(l.windowStartTime) < (30 * time.Second) {
-		l.currentTokenCount += tokens
-		if vars.Verbose {
-			_ = shared.Bar.Clear()
-			helpers.V("Recorded %d tokens. Total for this minute: %d", tokens, l.currentTokenCount)
+	l.exhaustedUntil = l.clock.Now().Add(24 * time.Hour)
+}
+
+// nextSlot returns how long to wait before a request fits, and which limit is the cause.
+func (l *RateLimiter) nextSlot(now time.Time, estimatedTokens int) (time.Duration, string) {
+	var wait time.Duration
+	var reason string
+	consider := func(d time.Duration, why string) {
+		if d > wait {
+			wait = d
+			reason = why
 		}
-	} else {
+	}
 
-		l.windowStartTime = time.Now()
-		l.currentTokenCount = tokens
-		if vars.Verbose {
-			_ = shared.Bar.Clear()
-			helpers.V("Window reset during API call. Recorded %d tokens for new window.", tokens)
+	if now.Before(l.blockedUntil) {
+		consider(l.blockedUntil.Sub(now), "retry delay requested by the API")
+	}
+
+	if rpm := l.limits.RequestsPerMinute; rpm > 0 && len(l.minuteRequests) >= rpm {
+		// The request that has to leave the window before we fit again
+		freeAt := l.minuteRequests[len(l.minuteRequests)-rpm].Add(time.Minute)
+		consider(freeAt.Sub(now), "requests per minute")
+	}
+
+	if tpm := l.limits.TokensPerMinute; tpm > 0 {
+		used := 0
+		for _, t := range l.tokens {
+			used += t.count
 		}
+		for _, t := range l.tokens {
+			if used+estimatedTokens <= tpm {
+				break
+			}
+			used -= t.count
+			consider(t.at.Add(time.Minute).Sub(now), "tokens per minute")
+		}
+	}
+
+	return wait, reason
+}
+
+// prune drops everything that fell out of its window.
+func (l *RateLimiter) prune(now time.Time) {
+	minuteAgo := now.Add(-time.Minute)
+	dayAgo := now.Add(-24 * time.Hour)
+
+	l.minuteRequests = dropBefore(l.minuteRequests, minuteAgo)
+	l.dayRequests = dropBefore(l.dayRequests, dayAgo)
+
+	i := 0
+	for i < len(l.tokens) && !l.tokens[i].at.After(minuteAgo) {
+		i++
+	}
+	l.tokens = l.tokens[i:]
+}
+
+func dropBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
 	}
+	return times[i:]
 }
@@ -0,0 +1,264 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/vars"
+	"google.golang.org/genai"
+)
+
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestRequestsPerMinute(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(vars.RateLimits{RequestsPerMinute: 2}, clock)
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Wait(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(10 * time.Second)
+	}
+	if len(clock.slept) != 0 {
+		t.Fatalf("expected no sleeps, got %v", clock.slept)
+	}
+
+	// First request was at +0s, it is now +20s, so the slot frees up at +60s.
+	if _, err := l.Wait(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 40*time.Second {
+		t.Fatalf("expected a single 40s sleep, got %v", clock.slept)
+	}
+}
+
+func TestTokensPerMinute(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(vars.RateLimits{TokensPerMinute: 1000}, clock)
+	ctx := context.Background()
+
+	r, err := l.Wait(ctx, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(r, 600)
+	clock.Advance(15 * time.Second)
+
+	if r, err = l.Wait(ctx, 300); err != nil {
+		t.Fatal(err)
+	}
+	l.Record(r, 300)
+	if len(clock.slept) != 0 {
+		t.Fatalf("expected no sleeps, got %v", clock.slept)
+	}
+
+	// 900 tokens used, 200 more don't fit until the first 600 leave the window at +60s.
+	clock.Advance(5 * time.Second)
+	if _, err := l.Wait(ctx, 200); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 40*time.Second {
+		t.Fatalf("expected a single 40s sleep, got %v", clock.slept)
+	}
+}
+
+func TestRecordReconcilesEstimate(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(vars.RateLimits{TokensPerMinute: 1000}, clock)
+	ctx := context.Background()
+
+	r, err := l.Wait(ctx, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The estimate was too high, the freed tokens are usable right away
+	l.Record(r, 100)
+	if _, err := l.Wait(ctx, 800); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 0 {
+		t.Fatalf("expected no sleeps after reconciling the estimate, got %v", clock.slept)
+	}
+
+	// Without a Record the 800 estimate stays reserved: 900 of 1000 are taken
+	if _, err := l.Wait(ctx, 200); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != time.Minute {
+		t.Fatalf("expected a single 1m sleep, got %v", clock.slept)
+	}
+}
+
+// frozenClock never advances, Sleep blocks until ctx is done.
+type frozenClock struct {
+	now      time.Time
+	sleeping atomic.Int32
+}
+
+func (c *frozenClock) Now() time.Time { return c.now }
+
+func (c *frozenClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeping.Add(1)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestConcurrentWaitReservesTokens(t *testing.T) {
+	clock := &frozenClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(vars.RateLimits{TokensPerMinute: 1000}, clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const workers = 10
+	var passed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := l.Wait(ctx, 300)
+			switch {
+			case err == nil:
+				passed.Add(1)
+			case !errors.Is(err, context.Canceled):
+				t.Errorf("Wait() = %v, want context.Canceled", err)
+			}
+		}()
+	}
+
+	// Only 3 requests of 300 tokens fit in 1000, the others wait for the window
+	deadline := time.Now().Add(5 * time.Second)
+	for passed.Load()+clock.sleeping.Load() < workers {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d passed and %d waiting, want all %d workers done or waiting", passed.Load(), clock.sleeping.Load(), workers)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	if got := passed.Load(); got != 3 {
+		t.Fatalf("%d concurrent requests of 300 tokens passed a 1000 TPM limit, want 3", got)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	l := NewRateLimiter(vars.RateLimits{RequestsPerMinute: 1}, newFakeClock())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Wait(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() with a canceled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestOversizedEstimateStillRuns(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(vars.RateLimits{TokensPerMinute: 100}, clock)
+
+	if _, err := l.Wait(context.Background(), 5000); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 0 {
+		t.Fatalf("an empty window should never wait, slept %v", clock.slept)
+	}
+}
+
+func TestRequestsPerDay(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(vars.RateLimits{RequestsPerDay: 2}, clock)
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Wait(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Wait(context.Background(), 0); !errors.Is(err, ErrDailyQuota) {
+		t.Fatalf("expected ErrDailyQuota, got %v", err)
+	}
+
+	clock.Advance(24*time.Hour + time.Second)
+	if _, err := l.Wait(context.Background(), 0); err != nil {
+		t.Fatalf("expected the daily window to roll over, got %v", err)
+	}
+}
+
+func TestBackoffAndExhaustDay(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(vars.RateLimits{}, clock)
+
+	l.Backoff(17 * time.Second)
+	var reasons []string
+	l.OnWait = func(_ time.Duration, reason string) { reasons = append(reasons, reason) }
+	if _, err := l.Wait(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 17*time.Second || len(reasons) != 1 {
+		t.Fatalf("expected one 17s sleep, got %v (%v)", clock.slept, reasons)
+	}
+
+	l.ExhaustDay()
+	if _, err := l.Wait(context.Background(), 0); !errors.Is(err, ErrDailyQuota) {
+		t.Fatalf("expected ErrDailyQuota, got %v", err)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	perMinute := genai.APIError{
+		Code:   429,
+		Status: "RESOURCE_EXHAUSTED",
+		Details: []map[string]any{
+			{
+				"@type": "type.googleapis.com/google.rpc.QuotaFailure",
+				"violations": []any{
+					map[string]any{"quotaId": "GenerateContentInputTokensPerModelPerMinute-FreeTier"},
+				},
+			},
+			{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "23s"},
+		},
+	}
+	kind, delay := classifyError(perMinute)
+	if kind != quotaMinute || delay != 23*time.Second {
+		t.Fatalf("got kind %v delay %v", kind, delay)
+	}
+
+	perDay := genai.APIError{
+		Code: 429,
+		Details: []map[string]any{
+			{
+				"@type": "type.googleapis.com/google.rpc.QuotaFailure",
+				"violations": []any{
+					map[string]any{"quotaId": "GenerateRequestsPerDayPerProjectPerModel-FreeTier"},
+				},
+			},
+		},
+	}
+	if kind, _ := classifyError(perDay); kind != quotaDay {
+		t.Fatalf("expected quotaDay, got %v", kind)
+	}
+
+	if kind, _ := classifyError(errors.New("boom")); kind != quotaNone {
+		t.Fatalf("expected quotaNone, got %v", kind)
+	}
+}
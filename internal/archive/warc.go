@@ -0,0 +1,249 @@
+// Package archive writes the pages a scan found to WARC 1.1 files, so a finding can be
+// shown exactly as it was served: request and response headers, body, final URL and time.
+package archive
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Exchange is one fetched page.
+type Exchange struct {
+	RequestedURL  string
+	URL           string   // final URL after redirects
+	Redirects     []string // URLs visited before URL, oldest first
+	Method        string
+	RequestHeader http.Header
+	Proto         string // e.g. HTTP/1.1
+	StatusCode    int
+	Status        string // e.g. 200 OK
+	Header        http.Header
+	Body          []byte // decompressed, Content-Encoding and Content-Length are not in Header
+	Date          time.Time
+	// Set for pages loaded in the headless browser: Body is the rendered DOM and there
+	// are no headers, so it is written as a resource record instead of request/response.
+	Rendered bool
+}
+
+// Record identifies the record an Exchange was written to. Hashes are hex encoded SHA-256.
+type Record struct {
+	ID            string
+	Date          time.Time
+	PayloadSHA256 string // the body
+	BlockSHA256   string // the whole HTTP response (status line, headers and body)
+}
+
+// Writer appends records to a WARC file. It is safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	f       *os.File
+	records int
+}
+
+// Create creates the WARC file at path and writes a warcinfo record with info.
+func Create(path string, info map[string]string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{f: f}
+
+	keys := make([]string, 0, len(info))
+	for key := range info {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var fields bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&fields, "%s: %s\r\n", key, info[key])
+	}
+	if err := w.writeRecord("warcinfo", "application/warc-fields", fields.Bytes(), nil); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Records is the number of exchanges written so far.
+func (w *Writer) Records() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.records
+}
+
+// WriteExchange writes ex as a response, request and metadata record (a resource and a
+// metadata record if it was rendered) and returns the response record.
+func (w *Writer) WriteExchange(ex Exchange) (Record, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	date := ex.Date.UTC()
+	if date.IsZero() {
+		date = time.Now().UTC()
+	}
+	id, err := newRecordID()
+	if err != nil {
+		return Record{}, err
+	}
+	common := []string{
+		"WARC-Record-ID", id,
+		"WARC-Date", date.Format(time.RFC3339Nano),
+		"WARC-Target-URI", ex.URL,
+		"WARC-Payload-Digest", digest(ex.Body),
+	}
+
+	var block []byte
+	if ex.Rendered {
+		block = ex.Body
+		if err := w.writeRecord("resource", "text/html", block, common); err != nil {
+			return Record{}, err
+		}
+	} else {
+		block = responseBlock(ex)
+		if err := w.writeRecord("response", "application/http;msgtype=response", block, common); err != nil {
+			return Record{}, err
+		}
+
+		requestID, err := newRecordID()
+		if err != nil {
+			return Record{}, err
+		}
+		err = w.writeRecord("request", "application/http;msgtype=request", requestBlock(ex), []string{
+			"WARC-Record-ID", requestID,
+			"WARC-Date", date.Format(time.RFC3339Nano),
+			"WARC-Target-URI", ex.URL,
+			"WARC-Concurrent-To", id,
+		})
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	if err := w.writeMetadata(ex, id, date); err != nil {
+		return Record{}, err
+	}
+	w.records++
+
+	payloadSum := sha256.Sum256(ex.Body)
+	blockSum := sha256.Sum256(block)
+	return Record{
+		ID:            id,
+		Date:          date,
+		PayloadSHA256: hex.EncodeToString(payloadSum[:]),
+		BlockSHA256:   hex.EncodeToString(blockSum[:]),
+	}, nil
+}
+
+// writeMetadata records how the page was reached: the requested URL and the redirects.
+func (w *Writer) writeMetadata(ex Exchange, refersTo string, date time.Time) error {
+	var fields bytes.Buffer
+	fmt.Fprintf(&fields, "requested-uri: %s\r\n", ex.RequestedURL)
+	for _, via := range ex.Redirects {
+		fmt.Fprintf(&fields, "via: %s\r\n", via)
+	}
+	if ex.Rendered {
+		fmt.Fprintf(&fields, "rendered: true\r\nstatus: %d\r\n", ex.StatusCode)
+	}
+
+	id, err := newRecordID()
+	if err != nil {
+		return err
+	}
+	return w.writeRecord("metadata", "application/warc-fields", fields.Bytes(), []string{
+		"WARC-Record-ID", id,
+		"WARC-Date", date.Format(time.RFC3339Nano),
+		"WARC-Target-URI", ex.URL,
+		"WARC-Refers-To", refersTo,
+	})
+}
+
+// writeRecord writes one record. headers are name, value pairs; the warcinfo record
+// gets its own ID and date.
+func (w *Writer) writeRecord(recordType string, contentType string, block []byte, headers []string) error {
+	if headers == nil {
+		id, err := newRecordID()
+		if err != nil {
+			return err
+		}
+		headers = []string{"WARC-Record-ID", id, "WARC-Date", time.Now().UTC().Format(time.RFC3339Nano)}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("WARC/1.1\r\n")
+	fmt.Fprintf(&buf, "WARC-Type: %s\r\n", recordType)
+	for i := 0; i+1 < len(headers); i += 2 {
+		fmt.Fprintf(&buf, "%s: %s\r\n", headers[i], headers[i+1])
+	}
+	fmt.Fprintf(&buf, "WARC-Block-Digest: %s\r\n", digest(block))
+	fmt.Fprintf(&buf, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(block))
+	buf.Write(block)
+	buf.WriteString("\r\n\r\n")
+
+	_, err := w.f.Write(buf.Bytes())
+	return err
+}
+
+// Close closes the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+func responseBlock(ex Exchange) []byte {
+	var buf bytes.Buffer
+	proto := ex.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	fmt.Fprintf(&buf, "%s %s\r\n", proto, ex.Status)
+	_ = ex.Header.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.Write(ex.Body)
+	return buf.Bytes()
+}
+
+func requestBlock(ex Exchange) []byte {
+	var buf bytes.Buffer
+	target, host := "/", ""
+	if u, err := url.Parse(ex.URL); err == nil {
+		target, host = u.RequestURI(), u.Host
+	}
+	method := ex.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", method, target)
+	fmt.Fprintf(&buf, "Host: %s\r\n", host)
+	_ = ex.RequestHeader.Write(&buf)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// digest formats a WARC digest, SHA-256 in base32 like the sha1 digests most tools write.
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func newRecordID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	h := hex.EncodeToString(b[:])
+	return fmt.Sprintf("<urn:uuid:%s-%s-%s-%s-%s>", h[:8], h[8:12], h[12:16], h[16:20], h[20:]), nil
+}
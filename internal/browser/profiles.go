@@ -0,0 +1,136 @@
+// Package browser has the request headers of real browsers, so that every request looks
+// like it comes from one browser instead of a random User-Agent with Go's headers.
+package browser
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Family is a browser engine and vendor, whose profiles share their headers.
+type Family string
+
+const (
+	Chrome  Family = "chrome"
+	Firefox Family = "firefox"
+	Safari  Family = "safari"
+)
+
+// Profile is the User-Agent and headers a browser sends when navigating to a page.
+// Accept-Encoding is left to the HTTP client, which can only decode what it asks for.
+type Profile struct {
+	Name      string
+	Family    Family
+	UserAgent string
+	Headers   map[string]string
+}
+
+// Apply sets the profile's headers on h, replacing the ones already set.
+func (p Profile) Apply(h http.Header) {
+	h.Set("User-Agent", p.UserAgent)
+	for name, value := range p.Headers {
+		h.Set(name, value)
+	}
+}
+
+const (
+	chromeAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+	chromeCHUA   = `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`
+	geckoAccept  = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+)
+
+func chrome(name string, userAgent string, platform string, mobile bool) Profile {
+	headers := map[string]string{
+		"Accept":                    chromeAccept,
+		"Accept-Language":           "en-US,en;q=0.9",
+		"Sec-Ch-Ua":                 chromeCHUA,
+		"Sec-Ch-Ua-Mobile":          "?0",
+		"Sec-Ch-Ua-Platform":        `"` + platform + `"`,
+		"Sec-Fetch-Dest":            "document",
+		"Sec-Fetch-Mode":            "navigate",
+		"Sec-Fetch-Site":            "none",
+		"Sec-Fetch-User":            "?1",
+		"Upgrade-Insecure-Requests": "1",
+		"Priority":                  "u=0, i",
+	}
+	if mobile {
+		headers["Sec-Ch-Ua-Mobile"] = "?1"
+	}
+	return Profile{Name: name, Family: Chrome, UserAgent: userAgent, Headers: headers}
+}
+
+func firefox(name string, userAgent string) Profile {
+	return Profile{Name: name, Family: Firefox, UserAgent: userAgent, Headers: map[string]string{
+		"Accept":                    geckoAccept,
+		"Accept-Language":           "en-US,en;q=0.5",
+		"Sec-Fetch-Dest":            "document",
+		"Sec-Fetch-Mode":            "navigate",
+		"Sec-Fetch-Site":            "none",
+		"Sec-Fetch-User":            "?1",
+		"Upgrade-Insecure-Requests": "1",
+		"Priority":                  "u=0, i",
+	}}
+}
+
+// Safari sends neither client hints nor Sec-Fetch-User
+func safari(name string, userAgent string) Profile {
+	return Profile{Name: name, Family: Safari, UserAgent: userAgent, Headers: map[string]string{
+		"Accept":          geckoAccept,
+		"Accept-Language": "en-US,en;q=0.9",
+		"Sec-Fetch-Dest":  "document",
+		"Sec-Fetch-Mode":  "navigate",
+		"Sec-Fetch-Site":  "none",
+		"Priority":        "u=0, i",
+	}}
+}
+
+// Profiles are the built-in profiles.
+var Profiles = []Profile{
+	chrome("chrome-windows", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36", "Windows", false),
+	chrome("chrome-mac", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36", "macOS", false),
+	chrome("chrome-linux", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36", "Linux", false),
+	chrome("chrome-android", "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36", "Android", true),
+	firefox("firefox-windows", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0"),
+	firefox("firefox-mac", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:133.0) Gecko/20100101 Firefox/133.0"),
+	firefox("firefox-linux", "Mozilla/5.0 (X11; Linux x86_64; rv:133.0) Gecko/20100101 Firefox/133.0"),
+	safari("safari-mac", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15"),
+	safari("safari-iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 18_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Mobile/15E148 Safari/604.1"),
+}
+
+// Lookup returns the profiles named name, or of the family name, or every profile for
+// "random" or "". It is empty if nothing matches.
+func Lookup(name string) []Profile {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "random" {
+		return Profiles
+	}
+	var found []Profile
+	for _, profile := range Profiles {
+		if profile.Name == name || string(profile.Family) == name {
+			found = append(found, profile)
+		}
+	}
+	return found
+}
+
+// Random picks one of the profiles named name, see Lookup.
+func Random(name string) (Profile, bool) {
+	found := Lookup(name)
+	if len(found) == 0 {
+		return Profile{}, false
+	}
+	return found[rand.Intn(len(found))], true
+}
+
+// Names lists the families and profile names, for flag help.
+func Names() []string {
+	names := []string{string(Chrome), string(Firefox), string(Safari)}
+	var profiles []string
+	for _, profile := range Profiles {
+		profiles = append(profiles, profile.Name)
+	}
+	sort.Strings(profiles)
+	return append(names, profiles...)
+}
@@ -0,0 +1,139 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileCookie is a cookie read from a file, with the host it was set for.
+type fileCookie struct {
+	*http.Cookie
+	host string
+}
+
+// url is a URL the cookie can be set from.
+func (c fileCookie) url() *url.URL {
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+	return &url.URL{Scheme: "https", Host: c.host, Path: path}
+}
+
+// loadCookies reads a Netscape cookies.txt or a JSON cookie export.
+func loadCookies(path string) ([]fileCookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return parseJSONCookies(trimmed)
+	}
+	return parseNetscapeCookies(data)
+}
+
+// parseNetscapeCookies parses the cookies.txt format of curl, wget and browser
+// extensions: domain, include subdomains, path, secure, expiry, name and value,
+// separated by tabs.
+func parseNetscapeCookies(data []byte) ([]fileCookie, error) {
+	var cookies []fileCookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line = rest
+			httpOnly = true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("cookies.txt line %d: expected 7 tab separated fields, got %d", n, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cookies.txt line %d: invalid expiry %q", n, fields[4])
+		}
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		host := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = host
+		}
+		cookies = append(cookies, fileCookie{Cookie: cookie, host: host})
+	}
+	return cookies, scanner.Err()
+}
+
+// jsonCookie is a cookie as exported by browser extensions such as Cookie-Editor and
+// EditThisCookie.
+type jsonCookie struct {
+	Domain         string  `json:"domain"`
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Path           string  `json:"path"`
+	Secure         bool    `json:"secure"`
+	HTTPOnly       bool    `json:"httpOnly"`
+	HostOnly       bool    `json:"hostOnly"`
+	Session        bool    `json:"session"`
+	ExpirationDate float64 `json:"expirationDate"`
+}
+
+func parseJSONCookies(data []byte) ([]fileCookie, error) {
+	var exported []jsonCookie
+	if data[0] == '{' {
+		// Some exports wrap the list: {"cookies": [...]}
+		var wrapped struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("invalid cookie export: %w", err)
+		}
+		exported = wrapped.Cookies
+	} else if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("invalid cookie export: %w", err)
+	}
+
+	var cookies []fileCookie
+	for _, c := range exported {
+		if c.Domain == "" || c.Name == "" {
+			continue
+		}
+		host := strings.TrimPrefix(c.Domain, ".")
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		if !c.HostOnly {
+			cookie.Domain = host
+		}
+		if !c.Session && c.ExpirationDate > 0 {
+			cookie.Expires = time.Unix(int64(c.ExpirationDate), 0)
+		}
+		cookies = append(cookies, fileCookie{Cookie: cookie, host: host})
+	}
+	return cookies, nil
+}
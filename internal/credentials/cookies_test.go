@@ -0,0 +1,102 @@
+package credentials
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNetscapeCookies(t *testing.T) {
+	data := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		".instagram.com\tTRUE\t/\tTRUE\t1893456000\tsessionid\tabc123",
+		"#HttpOnly_www.example.com\tFALSE\t/app\tFALSE\t0\tcsrftoken\txyz\r",
+		"# a comment\twith\ttabs",
+	}, "\n")
+
+	cookies, err := parseNetscapeCookies([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, want 2", len(cookies))
+	}
+
+	session := cookies[0]
+	if session.host != "instagram.com" || session.Domain != "instagram.com" {
+		t.Errorf("include subdomains: host %q, domain %q, want instagram.com for both", session.host, session.Domain)
+	}
+	if session.Name != "sessionid" || session.Value != "abc123" || !session.Secure || session.HttpOnly {
+		t.Errorf("sessionid = %+v", session.Cookie)
+	}
+	if !session.Expires.Equal(time.Unix(1893456000, 0)) {
+		t.Errorf("sessionid expires %v", session.Expires)
+	}
+
+	csrf := cookies[1]
+	if csrf.host != "www.example.com" || csrf.Domain != "" {
+		t.Errorf("host only: host %q, domain %q, want www.example.com and no domain", csrf.host, csrf.Domain)
+	}
+	if !csrf.HttpOnly || csrf.Secure || csrf.Value != "xyz" || !csrf.Expires.IsZero() {
+		t.Errorf("#HttpOnly_ cookie = %+v", csrf.Cookie)
+	}
+	if got := csrf.url().String(); got != "https://www.example.com/app" {
+		t.Errorf("url() = %s", got)
+	}
+}
+
+func TestParseNetscapeCookiesErrors(t *testing.T) {
+	tests := map[string]string{
+		"too few fields":  "# header\nexample.com\tTRUE\t/\tFALSE\t0\tname",
+		"too many fields": "example.com\tTRUE\t/\tFALSE\t0\tname\tvalue\textra",
+		"spaces":          "example.com TRUE / FALSE 0 name value",
+		"invalid expiry":  "example.com\tTRUE\t/\tFALSE\tnever\tname\tvalue",
+	}
+	for name, data := range tests {
+		if _, err := parseNetscapeCookies([]byte(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	_, err := parseNetscapeCookies([]byte("# header\n\nexample.com\tTRUE\t/"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "got 3") {
+		t.Errorf("error = %v, want it to name line 3 and the 3 fields", err)
+	}
+}
+
+func TestParseJSONCookies(t *testing.T) {
+	list := `[
+		{"domain": ".instagram.com", "name": "sessionid", "value": "abc123", "path": "/", "secure": true, "httpOnly": true, "expirationDate": 1893456000.5},
+		{"domain": "www.example.com", "hostOnly": true, "name": "csrftoken", "value": "xyz", "session": true, "expirationDate": 1893456000},
+		{"domain": "", "name": "nodomain", "value": "dropped"},
+		{"domain": "example.com", "name": "", "value": "dropped"}
+	]`
+	wrapped := `{"url": "https://www.instagram.com", "cookies": ` + list + `}`
+
+	for name, data := range map[string]string{"list": list, "wrapped": wrapped} {
+		cookies, err := parseJSONCookies([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(cookies) != 2 {
+			t.Fatalf("%s: got %d cookies, want 2", name, len(cookies))
+		}
+		session, csrf := cookies[0], cookies[1]
+		if session.host != "instagram.com" || session.Domain != "instagram.com" || !session.Secure || !session.HttpOnly {
+			t.Errorf("%s: sessionid = %+v (host %q)", name, session.Cookie, session.host)
+		}
+		if !session.Expires.Equal(time.Unix(1893456000, 0)) {
+			t.Errorf("%s: sessionid expires %v", name, session.Expires)
+		}
+		if csrf.host != "www.example.com" || csrf.Domain != "" || !csrf.Expires.IsZero() {
+			t.Errorf("%s: host only session cookie = %+v (host %q)", name, csrf.Cookie, csrf.host)
+		}
+	}
+
+	for _, data := range []string{`[{"name": 1}]`, `{"cookies": {}}`, `{broken`} {
+		if _, err := parseJSONCookies([]byte(data)); err == nil {
+			t.Errorf("parseJSONCookies(%s): no error", data)
+		}
+	}
+}
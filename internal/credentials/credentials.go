@@ -0,0 +1,280 @@
+// Package credentials loads the logins used for sites that only show profiles to
+// logged-in users: cookie jars and static headers, each limited to a set of domains.
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Redacted replaces secrets in logs and reports.
+const Redacted = "[REDACTED]"
+
+// Headers that always carry secrets, redacted whether or not a profile set them
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Secrets shorter than this are only redacted after their name, replacing them anywhere
+// would mangle unrelated text
+const minSecretLength = 6
+
+// Config is one profile of credentials.json.
+type Config struct {
+	// Sites the profile is used for, subdomains included, e.g. "instagram.com"
+	Domains []string `json:"domains"`
+	// Netscape cookies.txt or JSON cookie export (Cookie-Editor, EditThisCookie...),
+	// relative to credentials.json
+	Cookies string `json:"cookies"`
+	// Sent as is with every request to the domains, e.g. "Authorization": "Bearer ..."
+	Headers map[string]string `json:"headers"`
+}
+
+// Profile is a loaded credential profile.
+type Profile struct {
+	Name    string
+	Domains []string
+	Headers map[string]string
+	jar     *cookiejar.Jar // nil without cookies
+	cookies int
+	set     *Set
+}
+
+// Set is every profile of credentials.json, and the secrets they hold. It is safe for
+// concurrent use.
+type Set struct {
+	Profiles []*Profile
+
+	mu      sync.RWMutex
+	secrets []secret
+}
+
+// secret is the value of a cookie or header.
+type secret struct {
+	name, value string
+}
+
+// Load reads credentials.json and the cookie files it points to.
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs map[string]Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := &Set{}
+	for _, name := range names {
+		config := configs[name]
+		if len(config.Domains) == 0 {
+			return nil, fmt.Errorf("%s: profile %q has no domains", path, name)
+		}
+		profile := &Profile{Name: name, Headers: make(map[string]string), set: set}
+		for _, domain := range config.Domains {
+			profile.Domains = append(profile.Domains, strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "."))
+		}
+		for header, value := range config.Headers {
+			profile.Headers[http.CanonicalHeaderKey(header)] = value
+			set.addSecret(http.CanonicalHeaderKey(header), value)
+		}
+
+		if config.Cookies != "" {
+			cookiePath := config.Cookies
+			if !filepath.IsAbs(cookiePath) {
+				cookiePath = filepath.Join(filepath.Dir(path), cookiePath)
+			}
+			cookies, err := loadCookies(cookiePath)
+			if err != nil {
+				return nil, fmt.Errorf("profile %q: %w", name, err)
+			}
+			profile.jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+			for _, cookie := range cookies {
+				if !profile.matches(cookie.host) {
+					continue
+				}
+				profile.jar.SetCookies(cookie.url(), []*http.Cookie{cookie.Cookie})
+				profile.cookies++
+				set.addSecret(cookie.Name, cookie.Value)
+			}
+		}
+		set.Profiles = append(set.Profiles, profile)
+	}
+	return set, nil
+}
+
+func (s *Set) addSecret(name, value string) {
+	if value == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.secrets, secret{name, value}) {
+		s.secrets = append(s.secrets, secret{name, value})
+	}
+}
+
+// For returns the profile for requests to u, nil if there is none.
+func (s *Set) For(u *url.URL) *Profile {
+	if s == nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, profile := range s.Profiles {
+		if profile.matches(host) {
+			return profile
+		}
+	}
+	return nil
+}
+
+func (p *Profile) matches(host string) bool {
+	host = strings.TrimPrefix(host, ".")
+	for _, domain := range p.Domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// CookieCount is the number of cookies loaded for the profile's domains.
+func (p *Profile) CookieCount() int {
+	return p.cookies
+}
+
+// Cookies returns the profile's cookies for u.
+func (p *Profile) Cookies(u *url.URL) []*http.Cookie {
+	if p.jar == nil {
+		return nil
+	}
+	return p.jar.Cookies(u)
+}
+
+// SetCookies stores the cookies a response to u set, when the site refreshes its session.
+func (p *Profile) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if p.jar == nil || len(cookies) == 0 || !p.matches(u.Hostname()) {
+		return
+	}
+	p.jar.SetCookies(u, cookies)
+	for _, cookie := range cookies {
+		p.set.addSecret(cookie.Name, cookie.Value)
+	}
+}
+
+// Apply adds the profile's headers and cookies for u to h.
+func (p *Profile) Apply(h http.Header, u *url.URL) {
+	for name, value := range p.Headers {
+		h.Set(name, value)
+	}
+	for _, cookie := range p.Cookies(u) {
+		if existing := h.Get("Cookie"); existing != "" {
+			h.Set("Cookie", existing+"; "+cookie.String())
+		} else {
+			h.Set("Cookie", cookie.String())
+		}
+	}
+}
+
+// Redact replaces every secret of the set in text. Short secrets are only replaced after
+// their cookie or header name, as in "name=value" or "Name: value".
+func (s *Set) Redact(text string) string {
+	if s == nil {
+		return text
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, secret := range s.secrets {
+		if len(secret.value) >= minSecretLength {
+			text = strings.ReplaceAll(text, secret.value, Redacted)
+		} else {
+			text = redactByName(text, secret.name, secret.value)
+		}
+	}
+	return text
+}
+
+// redactByName replaces value where it follows name= or name: in text.
+func redactByName(text string, name string, value string) string {
+	if name == "" {
+		return text
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(text, name)
+		if i == -1 {
+			break
+		}
+		end := i + len(name)
+		rest := text[end:]
+		sep := ""
+		for _, candidate := range []string{"=", ": ", ":"} {
+			if strings.HasPrefix(rest, candidate) {
+				sep = candidate
+				break
+			}
+		}
+		if sep != "" && (i == 0 || !isTokenChar(text[i-1])) && strings.HasPrefix(rest[len(sep):], value) {
+			after := rest[len(sep)+len(value):]
+			if after == "" || !isTokenChar(after[0]) {
+				b.WriteString(text[:end+len(sep)])
+				b.WriteString(Redacted)
+				text = after
+				continue
+			}
+		}
+		b.WriteString(text[:end])
+		text = rest
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// isTokenChar reports whether c can be part of a cookie or header name or value.
+func isTokenChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~%+/", c) != -1
+}
+
+// RedactHeader returns a copy of h without secrets: the values of cookies, authorization
+// headers and the headers set by profiles are replaced.
+func (s *Set) RedactHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	redacted := h.Clone()
+	names := append([]string{}, sensitiveHeaders...)
+	if s != nil {
+		for _, profile := range s.Profiles {
+			for name := range profile.Headers {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted[http.CanonicalHeaderKey(name)] = []string{Redacted}
+		}
+	}
+	for _, values := range redacted {
+		for i, value := range values {
+			values[i] = s.Redact(value)
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,192 @@
+package credentials
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadSet writes credentials.json and the cookie files next to it, and loads them.
+func loadSet(t *testing.T, config string, files map[string]string) *Set {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "credentials.json")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	set, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+const testCredentials = `{
+	"instagram": {"domains": [".Instagram.com"], "cookies": "instagram.txt", "headers": {"x-ig-app-id": "936619743392459"}},
+	"api": {"domains": ["api.example.com"], "headers": {"Authorization": "Bearer s3cr3t-token", "X-Key": "k1"}}
+}`
+
+const testCookies = ".instagram.com\tTRUE\t/\tTRUE\t0\tsessionid\tabc123def456\n" +
+	"www.instagram.com\tFALSE\t/\tTRUE\t0\tds_user\t42\n" +
+	".evil.com\tTRUE\t/\tTRUE\t0\tstolen\tnot-for-instagram\n"
+
+func TestSetFor(t *testing.T) {
+	set := loadSet(t, testCredentials, map[string]string{"instagram.txt": testCookies})
+
+	tests := map[string]string{
+		"https://instagram.com/alice":        "instagram",
+		"https://www.instagram.com/alice":    "instagram",
+		"https://WWW.INSTAGRAM.COM/alice":    "instagram",
+		"https://i.cdn.instagram.com/a.jpg":  "instagram",
+		"https://evilinstagram.com/alice":    "",
+		"https://instagram.com.evil.com/":    "",
+		"https://api.example.com/users/bob":  "api",
+		"https://v2.api.example.com/":        "api",
+		"https://example.com/":               "",
+		"https://notapi.example.com/":        "",
+		"https://api.example.com:8443/users": "api",
+	}
+	for rawURL, want := range tests {
+		got := ""
+		if profile := set.For(mustParse(t, rawURL)); profile != nil {
+			got = profile.Name
+		}
+		if got != want {
+			t.Errorf("For(%s) = %q, want %q", rawURL, got, want)
+		}
+	}
+
+	var nilSet *Set
+	if nilSet.For(mustParse(t, "https://instagram.com/")) != nil {
+		t.Error("a nil set returned a profile")
+	}
+}
+
+func TestLoadCookiesForDomains(t *testing.T) {
+	set := loadSet(t, testCredentials, map[string]string{"instagram.txt": testCookies})
+	profile := set.For(mustParse(t, "https://www.instagram.com/"))
+
+	// The evil.com cookie is not for the profile's domains
+	if profile.CookieCount() != 2 {
+		t.Errorf("CookieCount() = %d, want 2", profile.CookieCount())
+	}
+	if profile.Headers["X-Ig-App-Id"] != "936619743392459" {
+		t.Errorf("headers = %v, want canonical names", profile.Headers)
+	}
+
+	h := http.Header{}
+	profile.Apply(h, mustParse(t, "https://www.instagram.com/alice"))
+	if cookie := h.Get("Cookie"); !strings.Contains(cookie, "sessionid=abc123def456") || !strings.Contains(cookie, "ds_user=42") {
+		t.Errorf("Cookie = %q", cookie)
+	}
+	h = http.Header{}
+	profile.Apply(h, mustParse(t, "https://instagram.com/alice"))
+	if cookie := h.Get("Cookie"); strings.Contains(cookie, "ds_user") || !strings.Contains(cookie, "sessionid") {
+		t.Errorf("Cookie = %q, want the host only cookie left out", cookie)
+	}
+
+	// Cookies set by other domains are ignored
+	profile.SetCookies(mustParse(t, "https://evilinstagram.com/"), []*http.Cookie{{Name: "sessionid", Value: "hijacked"}})
+	if cookies := profile.Cookies(mustParse(t, "https://www.instagram.com/")); len(cookies) != 2 {
+		t.Errorf("Cookies() = %v after a cookie from another domain", cookies)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, config := range map[string]string{
+		"no domains":     `{"x": {"headers": {"A": "b"}}}`,
+		"missing cookie": `{"x": {"domains": ["x.com"], "cookies": "missing.txt"}}`,
+		"invalid json":   `{"x": `,
+	} {
+		path := filepath.Join(dir, "credentials.json")
+		if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	set := loadSet(t, testCredentials, map[string]string{"instagram.txt": testCookies})
+	profile := set.For(mustParse(t, "https://www.instagram.com/"))
+	profile.SetCookies(mustParse(t, "https://www.instagram.com/"), []*http.Cookie{{Name: "rur", Value: "ab"}})
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"GET with Bearer s3cr3t-token failed", "GET with [REDACTED] failed"},
+		{"session abc123def456 expired", "session [REDACTED] expired"},
+		{"app 936619743392459", "app [REDACTED]"},
+		// Short secrets only after their name
+		{"Cookie: sessionid=abc123def456; ds_user=42; rur=ab", "Cookie: sessionid=[REDACTED]; ds_user=[REDACTED]; rur=[REDACTED]"},
+		{"X-Key: k1, X-Key:k1", "X-Key: [REDACTED], X-Key:[REDACTED]"},
+		{"user 42 has 42 posts, ds_user=420", "user 42 has 42 posts, ds_user=420"},
+		{"my_ds_user=42 rur=abc", "my_ds_user=42 rur=abc"},
+		{"nothing secret", "nothing secret"},
+	}
+	for _, tt := range tests {
+		if got := set.Redact(tt.text); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	var nilSet *Set
+	if got := nilSet.Redact("abc123def456"); got != "abc123def456" {
+		t.Errorf("a nil set redacted %q", got)
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	set := loadSet(t, testCredentials, map[string]string{"instagram.txt": testCookies})
+	h := http.Header{
+		"Cookie":              {"sessionid=abc123def456"},
+		"Set-Cookie":          {"a=b", "c=d"},
+		"Proxy-Authorization": {"Basic dXNlcjpwYXNz"},
+		"X-Ig-App-Id":         {"936619743392459"},
+		"X-Key":               {"k1"},
+		"Referer":             {"https://www.instagram.com/?sid=abc123def456"},
+		"Accept":              {"text/html"},
+	}
+
+	got := set.RedactHeader(h)
+	for _, name := range []string{"Cookie", "Set-Cookie", "Proxy-Authorization", "X-Ig-App-Id", "X-Key"} {
+		if values := got[name]; len(values) != 1 || values[0] != Redacted {
+			t.Errorf("%s = %q, want %s", name, values, Redacted)
+		}
+	}
+	if got.Get("Referer") != "https://www.instagram.com/?sid=[REDACTED]" || got.Get("Accept") != "text/html" {
+		t.Errorf("other headers = %v", got)
+	}
+	if h.Get("Cookie") != "sessionid=abc123def456" {
+		t.Error("RedactHeader changed the original header")
+	}
+
+	var nilSet *Set
+	if got := nilSet.RedactHeader(http.Header{"Authorization": {"Bearer x"}}); got.Get("Authorization") != Redacted {
+		t.Errorf("a nil set kept Authorization %q", got.Get("Authorization"))
+	}
+	if set.RedactHeader(nil) != nil {
+		t.Error("RedactHeader(nil) is not nil")
+	}
+}
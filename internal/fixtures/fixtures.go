@@ -0,0 +1,256 @@
+// Package fixtures records the HTTP exchanges of site lookups to one file per site, and
+// replays them offline, so detection can be tested without network access.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNoFixture is returned by a Replayer for requests that were never recorded.
+var ErrNoFixture = errors.New("no recorded response")
+
+// Redacted replaces the values of recorded cookies.
+const Redacted = "[REDACTED]"
+
+// Exchange is a recorded request and its response.
+type Exchange struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// Set instead of Body when the body is not text, e.g. compressed
+	BodyBase64 string `json:"body_base64,omitempty"`
+}
+
+// Fixture is every exchange recorded for one site, in order.
+type Fixture struct {
+	Site       string     `json:"site"` // scan URL template
+	RecordedAt time.Time  `json:"recorded_at"`
+	Exchanges  []Exchange `json:"exchanges"`
+}
+
+type siteKey struct{}
+
+// WithSite tags the requests made with ctx as lookups on site (its scan URL template),
+// the fixture they are recorded to and replayed from.
+func WithSite(ctx context.Context, site string) context.Context {
+	return context.WithValue(ctx, siteKey{}, site)
+}
+
+func siteFrom(ctx context.Context) string {
+	site, _ := ctx.Value(siteKey{}).(string)
+	return site
+}
+
+// FileName is the name of the fixture file of site: its scan URL template without the
+// scheme, every character other than letters, digits, '.' and '-' replaced with '_'.
+func FileName(site string) string {
+	if i := strings.Index(site, "://"); i != -1 {
+		site = site[i+3:]
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, site)
+	return strings.Trim(name, "_") + ".json"
+}
+
+// Load reads the fixture of site from dir.
+func Load(dir string, site string) (*Fixture, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName(site)))
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("%s: %w", FileName(site), err)
+	}
+	return &fixture, nil
+}
+
+// Save writes the fixture to dir.
+func (f *Fixture) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, FileName(f.Site)), append(data, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper that keeps the exchanges it makes through Transport,
+// by the site of their context. Requests without a site are not recorded.
+type Recorder struct {
+	Transport http.RoundTripper
+
+	mu       sync.Mutex
+	fixtures map[string]*Fixture
+}
+
+// NewRecorder records the exchanges made through transport, http.DefaultTransport if nil.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{Transport: transport, fixtures: make(map[string]*Fixture)}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := r.Transport.RoundTrip(req)
+	site := siteFrom(req.Context())
+	if err != nil || site == "" {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	exchange := Exchange{Method: req.Method, URL: req.URL.String(), Status: res.StatusCode, Header: redactCookies(res.Header)}
+	if utf8.Valid(body) {
+		exchange.Body = string(body)
+	} else {
+		exchange.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fixture := r.fixtures[site]
+	if fixture == nil {
+		fixture = &Fixture{Site: site, RecordedAt: time.Now().UTC()}
+		r.fixtures[site] = fixture
+	}
+	fixture.Exchanges = append(fixture.Exchanges, exchange)
+	return res, nil
+}
+
+// Save writes a fixture per recorded site to dir, replacing older ones.
+func (r *Recorder) Save(dir string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, fixture := range r.fixtures {
+		if err := fixture.Save(dir); err != nil {
+			return 0, err
+		}
+	}
+	return len(r.fixtures), nil
+}
+
+// redactCookies returns a copy of h with the values of the cookies set replaced, the
+// names are kept for block detection.
+func redactCookies(h http.Header) http.Header {
+	h = h.Clone()
+	for i, cookie := range h.Values("Set-Cookie") {
+		name, rest, _ := strings.Cut(cookie, "=")
+		_, attributes, hasAttributes := strings.Cut(rest, ";")
+		redacted := name + "=" + Redacted
+		if hasAttributes {
+			redacted += ";" + attributes
+		}
+		h["Set-Cookie"][i] = redacted
+	}
+	return h
+}
+
+// Replayer is an http.RoundTripper that answers requests with the exchanges recorded in
+// Dir for the site of their context, without network access. A URL requested more times
+// than it was recorded gets its last recorded response again.
+type Replayer struct {
+	Dir string
+
+	mu       sync.Mutex
+	fixtures map[string]*Fixture
+	served   map[string]int // site + method + URL -> times served
+}
+
+// NewReplayer replays the fixtures of dir.
+func NewReplayer(dir string) *Replayer {
+	return &Replayer{Dir: dir, fixtures: make(map[string]*Fixture), served: make(map[string]int)}
+}
+
+// Has reports whether site has a fixture.
+func (r *Replayer) Has(site string) bool {
+	_, err := os.Stat(filepath.Join(r.Dir, FileName(site)))
+	return err == nil
+}
+
+func (r *Replayer) fixture(site string) (*Fixture, error) {
+	if fixture, ok := r.fixtures[site]; ok {
+		return fixture, nil
+	}
+	fixture, err := Load(r.Dir, site)
+	if err != nil {
+		return nil, err
+	}
+	r.fixtures[site] = fixture
+	return fixture, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	site := siteFrom(req.Context())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fixture, err := r.fixture(site)
+	if err != nil {
+		return nil, fmt.Errorf("%w for %s %s: %v", ErrNoFixture, req.Method, req.URL, err)
+	}
+	var matches []Exchange
+	for _, exchange := range fixture.Exchanges {
+		if exchange.Method == req.Method && exchange.URL == req.URL.String() {
+			matches = append(matches, exchange)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoFixture, req.Method, req.URL)
+	}
+	key := site + " " + req.Method + " " + req.URL.String()
+	exchange := matches[min(r.served[key], len(matches)-1)]
+	r.served[key]++
+
+	body := []byte(exchange.Body)
+	if exchange.BodyBase64 != "" {
+		body, err = base64.StdEncoding.DecodeString(exchange.BodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", FileName(site), err)
+		}
+	}
+	header := exchange.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		StatusCode:    exchange.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
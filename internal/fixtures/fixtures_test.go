@@ -0,0 +1,163 @@
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testSite = "https://example.com/{U}"
+
+// newSite serves a profile at /alice, redirects /old to it and answers 404 otherwise.
+// Every answer to /count is one x longer.
+func newSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/alice":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t-value", Path: "/"})
+			_, _ = io.WriteString(w, "<html>alice's profile</html>")
+		case "/old":
+			http.Redirect(w, r, "/alice", http.StatusMovedPermanently)
+		case "/binary":
+			_, _ = w.Write([]byte{0x1f, 0x8b, 0xff, 0x00})
+		case "/count":
+			_, _ = io.WriteString(w, strings.Repeat("x", int(count.Add(1))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, client *http.Client, ctx context.Context, url string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, string(body)
+}
+
+// record makes the requests of paths through a Recorder and saves the fixture.
+func record(t *testing.T, srv *httptest.Server, dir string, paths ...string) {
+	t.Helper()
+	recorder := NewRecorder(nil)
+	client := &http.Client{Transport: recorder}
+	ctx := WithSite(context.Background(), testSite)
+	for _, path := range paths {
+		get(t, client, ctx, srv.URL+path)
+	}
+	if saved, err := recorder.Save(dir); err != nil || saved != 1 {
+		t.Fatalf("Save() = %d, %v, want 1 fixture", saved, err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := newSite(t)
+	dir := t.TempDir()
+	record(t, srv, dir, "/old", "/missing", "/binary")
+	srv.Close()
+
+	replayer := NewReplayer(dir)
+	if !replayer.Has(testSite) {
+		t.Fatalf("Has(%q) = false after recording", testSite)
+	}
+	client := &http.Client{Transport: replayer}
+	ctx := WithSite(context.Background(), testSite)
+
+	res, body := get(t, client, ctx, srv.URL+"/old")
+	if res.StatusCode != http.StatusOK || body != "<html>alice's profile</html>" {
+		t.Errorf("replayed redirect = %d %q, want the profile", res.StatusCode, body)
+	}
+	if res.Request.URL.Path != "/alice" {
+		t.Errorf("redirect was not followed, final URL %s", res.Request.URL)
+	}
+
+	if res, _ := get(t, client, ctx, srv.URL+"/missing"); res.StatusCode != http.StatusNotFound {
+		t.Errorf("replayed /missing status = %d, want 404", res.StatusCode)
+	}
+	if _, body := get(t, client, ctx, srv.URL+"/binary"); body != string([]byte{0x1f, 0x8b, 0xff, 0x00}) {
+		t.Errorf("replayed binary body = %q", body)
+	}
+}
+
+func TestReplayRedactsCookies(t *testing.T) {
+	srv := newSite(t)
+	dir := t.TempDir()
+	record(t, srv, dir, "/alice")
+
+	fixture, err := Load(dir, testSite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := fixture.Exchanges[0].Header.Get("Set-Cookie")
+	if strings.Contains(cookie, "s3cr3t-value") {
+		t.Errorf("cookie value recorded: %q", cookie)
+	}
+	if !strings.HasPrefix(cookie, "session="+Redacted) || !strings.Contains(cookie, "Path=/") {
+		t.Errorf("Set-Cookie = %q, want the name and attributes kept", cookie)
+	}
+}
+
+func TestReplayInOrder(t *testing.T) {
+	srv := newSite(t)
+	dir := t.TempDir()
+	record(t, srv, dir, "/count", "/count")
+
+	client := &http.Client{Transport: NewReplayer(dir)}
+	ctx := WithSite(context.Background(), testSite)
+	for i, want := range []string{"x", "xx", "xx"} {
+		if _, body := get(t, client, ctx, srv.URL+"/count"); body != want {
+			t.Errorf("request %d = %q, want %q", i+1, body, want)
+		}
+	}
+}
+
+func TestReplayUnrecorded(t *testing.T) {
+	srv := newSite(t)
+	dir := t.TempDir()
+	record(t, srv, dir, "/alice")
+
+	client := &http.Client{Transport: NewReplayer(dir)}
+	for name, ctx := range map[string]context.Context{
+		"unrecorded URL":  WithSite(context.Background(), testSite),
+		"unrecorded site": WithSite(context.Background(), "https://other.example/{U}"),
+	} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/bob", nil)
+		_, err := client.Do(req)
+		if !errors.Is(err, ErrNoFixture) {
+			t.Errorf("%s: err = %v, want ErrNoFixture", name, err)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/{U}":                        "github.com__U.json",
+		"https://{U}.bandcamp.com":                      "U_.bandcamp.com.json",
+		"https://steamcommunity.com/groups/{U}":         "steamcommunity.com_groups__U.json",
+		"https://www.roblox.com/user.aspx?username={U}": "www.roblox.com_user.aspx_username__U.json",
+	}
+	for site, want := range tests {
+		if got := FileName(site); got != want {
+			t.Errorf("FileName(%q) = %q, want %q", site, got, want)
+		}
+	}
+}
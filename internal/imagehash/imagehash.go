@@ -0,0 +1,107 @@
+// Package imagehash computes the hashes used to recognize profile pictures: SHA-256 for
+// exact copies and a difference hash (dHash) for near-identical images, such as the same
+// avatar re-encoded or resized by another site.
+package imagehash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+
+	// Decoders for the formats avatars come in
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// NearIdentical is the largest dHash distance at which two images are considered the same picture.
+const NearIdentical = 6
+
+// Hashes of a single image.
+type Hashes struct {
+	SHA256 string // hex encoded
+	DHash  uint64
+	Format string // as reported by image.Decode, e.g. "png"
+}
+
+// Compute decodes data and hashes it.
+func Compute(data []byte) (Hashes, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Hashes{}, fmt.Errorf("could not decode image: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return Hashes{SHA256: hex.EncodeToString(sum[:]), DHash: DHash(img), Format: format}, nil
+}
+
+// DHash returns the 64 bit difference hash of img: the image is shrunk to 9x8 gray pixels
+// and every bit tells whether a pixel is darker than its right neighbour.
+func DHash(img image.Image) uint64 {
+	const width, height = 9, 8
+	var gray [height][width]uint64
+
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	for y := 0; y < height; y++ {
+		y0, y1 := cell(b.Min.Y, b.Dy(), y, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := cell(b.Min.X, b.Dx(), x, width)
+			// Integer luma, so that cells of the same color always compare equal
+			var sum uint64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += uint64(299*r + 587*g + 114*bl)
+				}
+			}
+			gray[y][x] = sum / uint64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			if gray[y][x] < gray[y][x+1] {
+				hash |= 1 << uint(y*(width-1)+x)
+			}
+		}
+	}
+	return hash
+}
+
+// cell returns the pixel range [start, end) of cell i out of n along a side of size pixels,
+// at least one pixel wide.
+func cell(min, size, i, n int) (int, int) {
+	start := min + i*size/n
+	end := min + (i+1)*size/n
+	if start >= min+size {
+		start = min + size - 1
+	}
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// Distance is the number of bits that differ between two dHashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatDHash formats a dHash as 16 hex digits.
+func FormatDHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseDHash parses a dHash formatted by FormatDHash.
+func ParseDHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
@@ -0,0 +1,127 @@
+package imagehash
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// gradient returns a w x h gray image getting brighter to the right, or darker if reverse is set.
+func gradient(w, h int, reverse bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / (w - 1))
+			if reverse {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func uniform(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		want uint64
+	}{
+		{"brighter to the right", gradient(90, 80, false), ^uint64(0)},
+		{"darker to the right", gradient(90, 80, true), 0},
+		{"smaller than 9x8", gradient(3, 2, false), 0x2424242424242424},
+		{"uniform gray", uniform(64, 64, color.Gray{Y: 128}), 0},
+		{"uniform red", uniform(200, 100, color.RGBA{R: 255, A: 255}), 0},
+		{"empty", image.NewGray(image.Rect(0, 0, 0, 0)), 0},
+	}
+
+	for _, tt := range tests {
+		if got := DHash(tt.img); got != tt.want {
+			t.Errorf("%s: DHash() = %s, want %s", tt.name, FormatDHash(got), FormatDHash(tt.want))
+		}
+	}
+}
+
+func TestDHashNearIdentical(t *testing.T) {
+	// Two unrelated uniform pictures hash the same, which is why blank placeholders
+	// belong in avatar_blocklist.txt
+	gray, red := DHash(uniform(64, 64, color.Gray{Y: 200})), DHash(uniform(32, 32, color.RGBA{R: 255, A: 255}))
+	if gray != 0 || red != 0 || Distance(gray, red) > NearIdentical {
+		t.Errorf("uniform images hash to %s and %s, want both 0 and matching", FormatDHash(gray), FormatDHash(red))
+	}
+
+	// The same picture at another size stays near-identical, its mirror image doesn't
+	a, b := DHash(gradient(90, 80, false)), DHash(gradient(450, 400, false))
+	if d := Distance(a, b); d > NearIdentical {
+		t.Errorf("resized image is %d bits away, want at most %d", d, NearIdentical)
+	}
+	if d := Distance(a, DHash(gradient(90, 80, true))); d <= NearIdentical {
+		t.Errorf("mirrored image is only %d bits away", d)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, ^uint64(0), 64},
+		{0b1011, 0b0001, 2},
+		{0x8000000000000000, 1, 2},
+		{0xf0f0f0f0f0f0f0f0, 0xf0f0f0f0f0f0f0f0, 0},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Distance(tt.b, tt.a); got != tt.want {
+			t.Errorf("Distance(%x, %x) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestFormatParseDHash(t *testing.T) {
+	for _, hash := range []uint64{0, 1, 0x2424242424242424, ^uint64(0)} {
+		s := FormatDHash(hash)
+		if len(s) != 16 {
+			t.Errorf("FormatDHash(%x) = %q, want 16 hex digits", hash, s)
+		}
+		if got, err := ParseDHash(s); err != nil || got != hash {
+			t.Errorf("ParseDHash(%q) = %x, %v, want %x", s, got, err, hash)
+		}
+	}
+	if _, err := ParseDHash("not a hash"); err == nil {
+		t.Error("ParseDHash accepted an invalid hash")
+	}
+}
+
+func TestCompute(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(90, 80, false)); err != nil {
+		t.Fatal(err)
+	}
+	hashes, err := Compute(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashes.Format != "png" || hashes.DHash != ^uint64(0) || len(hashes.SHA256) != 64 {
+		t.Errorf("Compute() = %+v", hashes)
+	}
+
+	if _, err := Compute([]byte("<html>not an image</html>")); err == nil {
+		t.Error("Compute accepted data that isn't an image")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/KillAllChickens/argus/internal/helpers"
 	"github.com/KillAllChickens/argus/internal/shared"
+	"github.com/KillAllChickens/argus/internal/sites"
 	"github.com/KillAllChickens/argus/internal/vars"
 )
 
@@ -37,11 +37,6 @@ func InitPaths(CustomConfigPath string) {
 	vars.ConfigDir = configDir
 	vars.ConfigSourcesLocation = filepath.Join(configDir, "sources.txt")
 	shared.ArtworkFile = GetConfigFile("artworks.txt")
-	UserAgenDir, err := GetFilePath("UserAgents.txt")
-	helpers.HandleErr(err)
-	UserAgents, err := NewlineSeperatedFileToArray(UserAgenDir)
-	helpers.HandleErr(err)
-	vars.UserAgents = UserAgents
 }
 
 func ensureDefaultConfigExists(configDir string) {
@@ -168,8 +163,8 @@ func GetFilePath(name string) (string, error) {
 	return FilePath, nil
 }
 
-func GetSources() ([]string, error) {
-	var sources []string
+func GetSources() ([]sites.Site, error) {
+	var sources []sites.Site
 	var sourcesFilePath string
 
 	// Find sources.txt in config dir
@@ -199,10 +194,14 @@ func GetSources() ([]string, error) {
 
 	// Split by newline and trim
 	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
+	for i, line := range lines {
 		line = strings.TrimSpace(line)
 		if line != "" && !strings.HasPrefix(line, "#") {
-			sources = append(sources, line)
+			site, err := sites.Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("sources.txt line %d: %w", i+1, err)
+			}
+			sources = append(sources, site)
 		}
 	}
 
@@ -251,18 +250,6 @@ func NewlineSeperatedFileToArray(filePath string) ([]string, error) {
 	return list, nil
 }
 
-func GetRandomUserAgent() string {
-	// userAgentFilePath, err := GetFilePath("UserAgents.txt")
-	// if err != nil {
-	// 	return "Argus-Panoptes/0.0.1"
-	// }
-	// if userAgentFilePath == "" {
-	// 	return "Argus-Panoptes/0.0.1"
-	// }
-	userAgents := vars.UserAgents
-	return userAgents[rand.Intn(len(userAgents))]
-}
-
 func GetConfigFile(filename string) string {
 	FilePath, err := GetFilePath(filename)
 	helpers.HandleErr(err)
@@ -0,0 +1,414 @@
+package output
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	argusio "github.com/KillAllChickens/argus/internal/io"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+const (
+	// ManifestFileName is the manifest written next to the reports of every run
+	ManifestFileName = "manifest.json"
+	// ed25519 key in the config directory, in PEM (PKCS #8), used to sign manifests
+	manifestKeyName = "manifest_key.pem"
+)
+
+// Manifest lists everything needed to show a run's reports weren't altered: how the
+// scan was run, which configuration it used and the hash of every file it wrote.
+type Manifest struct {
+	Tool        string             `json:"tool"`
+	Version     string             `json:"version"`
+	StartedAt   string             `json:"started_at"`
+	FinishedAt  string             `json:"finished_at"`
+	Parameters  ManifestParameters `json:"parameters"`
+	ConfigFiles []ManifestFile     `json:"config_files"` // relative to the config directory
+	Files       []ManifestFile     `json:"files"`        // relative to the manifest
+	// Covers the manifest without this field, as compact JSON
+	Signature *ManifestSignature `json:"signature,omitempty"`
+}
+
+// ManifestParameters are the options of the scan.
+type ManifestParameters struct {
+	Usernames     []string `json:"usernames"`
+	OutputTypes   []string `json:"output_types"`
+	Threads       int      `json:"threads"`
+	AI            bool     `json:"ai"`
+	AIProvider    string   `json:"ai_provider,omitempty"`
+	AIModel       string   `json:"ai_model,omitempty"`
+	AIVision      bool     `json:"ai_vision"`
+	AIFailPolicy  string   `json:"ai_fail_policy,omitempty"`
+	DeepScan      bool     `json:"deep_scan"`
+	PivotDepth    int      `json:"pivot_depth"`
+	PivotMax      int      `json:"pivot_max,omitempty"`
+	SaveImages    bool     `json:"save_images"`
+	Archive       bool     `json:"archive"`
+	Proxies       []string `json:"proxies,omitempty"` // passwords redacted
+	ProxyStrategy string   `json:"proxy_strategy,omitempty"`
+	Browser       string   `json:"browser"`
+	Credentials   []string `json:"credentials,omitempty"` // credentials.json profiles, without secrets
+	Tor           bool     `json:"tor"`
+	// Requests between Tor circuit rotations, 0 only rotates when blocked
+	TorRotateEvery int  `json:"tor_rotate_every,omitempty"`
+	TorIsolation   bool `json:"tor_isolation,omitempty"`
+	// Filters that narrowed sources.txt, and the number of sites left
+	SiteFilters  *sites.Filter `json:"site_filters,omitempty"`
+	SitesScanned int           `json:"sites_scanned"`
+}
+
+// ManifestFile is a file and its hash.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// ManifestSignature is an ed25519 signature, key and value base64 encoded.
+type ManifestSignature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+	Value     string `json:"value"`
+}
+
+// Config files that change what a scan finds or reports, hashed into the manifest if present
+var manifestConfigFiles = []string{
+	"sources.txt",
+	"deepscan.json",
+	"404checks.txt",
+	"blockchecks.txt",
+	"BadRedirects.txt",
+	"avatar_blocklist.txt",
+	"html_check.txt",
+	"vision_check.txt",
+	"html_template.html",
+}
+
+// Every file written by this run, for the manifest
+var writtenFiles []string
+
+// WriteManifest writes the manifest of this run to the output folder, signed if the
+// config directory has a manifest_key.pem.
+func WriteManifest() {
+	m := Manifest{
+		Tool:        "argus",
+		Version:     vars.Version,
+		StartedAt:   vars.ScanStartedAt.UTC().Format(time.RFC3339),
+		FinishedAt:  time.Now().UTC().Format(time.RFC3339),
+		Parameters:  manifestParameters(),
+		ConfigFiles: []ManifestFile{},
+		Files:       []ManifestFile{},
+	}
+
+	for _, name := range manifestConfigFiles {
+		path, err := argusio.GetFilePath(name)
+		if err != nil || path == "" {
+			continue
+		}
+		file, err := hashFile(path, vars.ConfigDir)
+		if err != nil {
+			printer.Error("Could not hash %s for the manifest: %v", path, err)
+			continue
+		}
+		m.ConfigFiles = append(m.ConfigFiles, file)
+	}
+	for _, path := range writtenFiles {
+		file, err := hashFile(path, vars.OutputFolder)
+		if err != nil {
+			printer.Error("Could not hash %s for the manifest: %v", path, err)
+			continue
+		}
+		m.Files = append(m.Files, file)
+	}
+
+	signed := false
+	if key, err := loadManifestKey(); err != nil {
+		printer.Error("Could not load %s, the manifest will not be signed: %v", manifestKeyName, err)
+	} else if key != nil {
+		if err := m.sign(key); err != nil {
+			printer.Error("Could not sign the manifest: %v", err)
+		} else {
+			signed = true
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		printer.Error("Could not write the manifest: %v", err)
+		return
+	}
+	path := filepath.Join(vars.OutputFolder, ManifestFileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		printer.Error("Could not write the manifest: %v", err)
+		return
+	}
+	if signed {
+		printer.Info("Wrote signed manifest %s", path)
+	} else {
+		printer.Info("Wrote manifest %s", path)
+	}
+}
+
+func manifestParameters() ManifestParameters {
+	params := ManifestParameters{
+		Usernames:   vars.Usernames,
+		OutputTypes: vars.OutputTypes,
+		Threads:     vars.Threads,
+		AI:          vars.AI,
+		AIVision:    vars.AIVision,
+		DeepScan:    vars.DeepScanEnabled,
+		PivotDepth:  vars.PivotDepth,
+		SaveImages:  vars.SaveImages,
+		Archive:     vars.Archive,
+		Browser:     vars.BrowserProfile,
+		Credentials: vars.CredentialProfiles,
+		Tor:         vars.UseTor,
+
+		SitesScanned: vars.SitesScanned,
+	}
+	if !vars.SiteFilter.IsEmpty() {
+		params.SiteFilters = &vars.SiteFilter
+	}
+	if vars.AI {
+		params.AIProvider = vars.AIProvider
+		params.AIModel = vars.AIModel
+		params.AIFailPolicy = vars.AIFailPolicy
+	}
+	if vars.PivotDepth > 0 {
+		params.PivotMax = vars.PivotMax
+	}
+	if vars.UseTor {
+		params.TorRotateEvery = vars.Tor.RotateEvery
+		params.TorIsolation = vars.Tor.IsolateSites
+	}
+	for _, proxy := range vars.Proxies {
+		if u, err := url.Parse(proxy); err == nil {
+			proxy = u.Redacted()
+		}
+		params.Proxies = append(params.Proxies, proxy)
+		params.ProxyStrategy = vars.ProxyStrategy
+	}
+	return params
+}
+
+// signedBytes is what the signature covers: the manifest without its signature.
+func (m Manifest) signedBytes() ([]byte, error) {
+	m.Signature = nil
+	return json.Marshal(m)
+}
+
+func (m *Manifest) sign(key ed25519.PrivateKey) error {
+	data, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	m.Signature = &ManifestSignature{
+		Algorithm: "ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}
+	return nil
+}
+
+// VerifyManifest checks the signature of a manifest and the hash of every file it lists,
+// printing each problem. keyPath is the trusted public (or private) key; when empty, the
+// config directory's manifest_key.pem is used if there is one. An unsigned manifest fails
+// when there is a trusted key or requireSignature is set, as anyone could have rewritten
+// its hashes.
+func VerifyManifest(path string, keyPath string, requireSignature bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("%s is not a valid manifest: %w", path, err)
+	}
+
+	problems := 0
+	if err := m.verifySignature(keyPath, requireSignature); err != nil {
+		printer.Error("Signature: %v", err)
+		problems++
+	}
+
+	dir := filepath.Dir(path)
+	for _, file := range m.Files {
+		current, err := hashFile(filepath.Join(dir, filepath.FromSlash(file.Path)), dir)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			printer.Error("%s is missing", file.Path)
+			problems++
+		case err != nil:
+			printer.Error("%s: %v", file.Path, err)
+			problems++
+		case current.SHA256 != file.SHA256:
+			printer.Error("%s was modified (SHA-256 %s, expected %s)", file.Path, current.SHA256, file.SHA256)
+			problems++
+		default:
+			printer.Success("%s", file.Path)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%s failed verification with %d problem(s)", path, problems)
+	}
+	printer.Success("%d files match %s", len(m.Files), path)
+	return nil
+}
+
+func (m Manifest) verifySignature(keyPath string, requireSignature bool) error {
+	trusted, err := trustedKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	if m.Signature == nil {
+		switch {
+		case trusted != nil:
+			return fmt.Errorf("the manifest is not signed, but it must be signed with the trusted key")
+		case requireSignature:
+			return fmt.Errorf("the manifest is not signed (--require-signature)")
+		}
+		printer.Warning("The manifest is not signed, only the file hashes can be checked")
+		return nil
+	}
+	if m.Signature.Algorithm != "ed25519" {
+		return fmt.Errorf("unsupported algorithm %q", m.Signature.Algorithm)
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(m.Signature.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature.Value)
+	if err != nil {
+		return fmt.Errorf("invalid signature")
+	}
+	data, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return fmt.Errorf("does not match, the manifest was modified")
+	}
+
+	if trusted == nil {
+		printer.Warning("Signature is valid, but no trusted key was given (--key) to check who signed it")
+		return nil
+	}
+	if !trusted.Equal(ed25519.PublicKey(publicKey)) {
+		return fmt.Errorf("valid, but made with a different key than the trusted one")
+	}
+	printer.Success("Signature is valid and made with the trusted key")
+	return nil
+}
+
+// trustedKey loads the key at keyPath, or the config directory's manifest_key.pem when
+// keyPath is empty. It is nil when there is neither.
+func trustedKey(keyPath string) (ed25519.PublicKey, error) {
+	if keyPath != "" {
+		return loadPublicKey(keyPath)
+	}
+	key, err := loadManifestKey()
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", manifestKeyName, err)
+	}
+	if key == nil {
+		return nil, nil
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// loadManifestKey reads manifest_key.pem from the config directory, nil if there is none.
+func loadManifestKey() (ed25519.PrivateKey, error) {
+	path, err := argusio.GetFilePath(manifestKeyName)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKey(block)
+}
+
+// loadPublicKey reads a PEM public key, or the public half of a private key.
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PRIVATE KEY" {
+		key, err := parsePrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 key", path)
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (ed25519.PrivateKey, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ed25519 key")
+	}
+	return key, nil
+}
+
+// hashFile hashes the file at path, recording it relative to base.
+func hashFile(path string, base string) (ManifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		rel = path
+	}
+	return ManifestFile{Path: filepath.ToSlash(rel), SHA256: hex.EncodeToString(h.Sum(nil)), Size: size}, nil
+}
@@ -0,0 +1,138 @@
+package output
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// writeRun writes a report and its manifest, signed with key unless it is nil, and
+// returns the manifest path.
+func writeRun(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	dir := t.TempDir()
+	report := filepath.Join(dir, "alice.json")
+	if err := os.WriteFile(report, []byte(`{"found": ["https://github.com/alice"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := hashFile(report, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Manifest{Tool: "argus", Files: []ManifestFile{file}}
+	if key != nil {
+		if err := m.sign(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeManifest(t, filepath.Join(dir, ManifestFileName), m)
+	return filepath.Join(dir, ManifestFileName)
+}
+
+func writeManifest(t *testing.T, path string, m Manifest) {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writePublicKey writes the public half of key as PEM and returns its path.
+func writePublicKey(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "manifest_key.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// useConfigDir points the config directory at an empty one, without manifest_key.pem.
+func useConfigDir(t *testing.T) {
+	t.Helper()
+	previous := vars.ConfigDir
+	vars.ConfigDir = t.TempDir()
+	t.Cleanup(func() { vars.ConfigDir = previous })
+}
+
+func TestVerifyManifest(t *testing.T) {
+	useConfigDir(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := writePublicKey(t, key)
+
+	if err := VerifyManifest(writeRun(t, key), trusted, false); err != nil {
+		t.Errorf("signed with the trusted key: %v", err)
+	}
+	if err := VerifyManifest(writeRun(t, otherKey), trusted, false); err == nil {
+		t.Error("signed with another key: verification passed")
+	}
+	if err := VerifyManifest(writeRun(t, nil), "", false); err != nil {
+		t.Errorf("unsigned without a trusted key: %v", err)
+	}
+	if err := VerifyManifest(writeRun(t, nil), "", true); err == nil {
+		t.Error("unsigned with --require-signature: verification passed")
+	}
+	if err := VerifyManifest(writeRun(t, nil), trusted, false); err == nil {
+		t.Error("unsigned with a trusted key: verification passed")
+	}
+}
+
+// TestVerifyTamperedManifest edits a report, puts its new hash in the manifest and drops
+// the signature, which must not pass when a trusted key is known.
+func TestVerifyTamperedManifest(t *testing.T) {
+	useConfigDir(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeRun(t, key)
+	dir := filepath.Dir(path)
+
+	report := filepath.Join(dir, "alice.json")
+	if err := os.WriteFile(report, []byte(`{"found": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := hashFile(report, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeManifest(t, path, Manifest{Tool: "argus", Files: []ManifestFile{file}})
+
+	if err := VerifyManifest(path, writePublicKey(t, key), false); err == nil {
+		t.Error("tampered and unsigned manifest passed verification with --key")
+	}
+
+	// With the signing key in the config directory instead of --key
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(vars.ConfigDir, manifestKeyName), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyManifest(path, "", false); err == nil {
+		t.Errorf("tampered and unsigned manifest passed verification with %s", manifestKeyName)
+	}
+}
@@ -15,6 +15,8 @@ import (
 
 	"github.com/KillAllChickens/argus/internal/helpers"
 	"github.com/KillAllChickens/argus/internal/io"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
 	"github.com/KillAllChickens/argus/internal/vars"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -23,14 +25,29 @@ import (
 )
 
 type jsonSiteResult struct {
-	URL      string               `json:"url"`
-	DeepScan *vars.DeepScanResult `json:"deep_scan_results,omitempty"`
+	URL              string               `json:"url"`
+	Unverified       bool                 `json:"unverified,omitempty"`
+	UnverifiedReason string               `json:"unverified_reason,omitempty"`
+	Confidence       float64              `json:"confidence"`
+	VisionVerdict    string               `json:"vision_verdict,omitempty"`
+	Variant          *vars.VariantInfo    `json:"variant,omitempty"`
+	Provenance       []vars.PivotStep     `json:"provenance,omitempty"`
+	Archive          *vars.ArchiveRecord  `json:"archive,omitempty"`
+	Credentials      string               `json:"credentials,omitempty"`
+	Avatar           *vars.AvatarInfo     `json:"avatar,omitempty"`
+	DeepScan         *vars.DeepScanResult `json:"deep_scan_results,omitempty"`
 }
 
 type outputJSONStruct struct {
 	Username  string                    `json:"username"`
 	Timestamp string                    `json:"timestamp"`
 	Results   map[string]jsonSiteResult `json:"sites"`
+	// Sites that answered with a block or captcha page, their result is unknown
+	Blocked map[string]vars.BlockedSite `json:"blocked,omitempty"`
+	Archive *vars.ArchiveFile           `json:"archive,omitempty"`
+	// Number of sites scanned, and the filters that narrowed sources.txt
+	SitesScanned int           `json:"sites_scanned"`
+	SiteFilters  *sites.Filter `json:"site_filters,omitempty"`
 }
 
 // for pdf file
@@ -44,11 +61,28 @@ func OutputJSON() {
 			Username:  username,
 			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
 			Results:   make(map[string]jsonSiteResult),
+			Blocked:   vars.BlockedSites[username],
+			Archive:   vars.ArchiveFiles[username],
+
+			SitesScanned: vars.SitesScanned,
+		}
+		if !vars.SiteFilter.IsEmpty() {
+			data.SiteFilters = &vars.SiteFilter
 		}
 
 		for siteName, siteURL := range vars.FoundSites[username] {
+			info := vars.FindingInfos[username][siteName]
 			result := jsonSiteResult{
-				URL: siteURL,
+				URL:              siteURL,
+				Unverified:       info.Unverified,
+				UnverifiedReason: info.UnverifiedReason,
+				Confidence:       info.Confidence,
+				VisionVerdict:    info.VisionVerdict,
+				Variant:          info.Variant,
+				Provenance:       info.Provenance,
+				Archive:          info.Archive,
+				Credentials:      info.Credentials,
+				Avatar:           info.Avatar,
 			}
 			if deepScanData, ok := vars.DeepScanResults[username][siteName]; ok {
 				result.DeepScan = &deepScanData
@@ -71,17 +105,34 @@ func OutputHTML() {
 		data := map[string]any{
 			"Username":        username,
 			"Sites":           vars.FoundSites[username],
-			"PFPs":            vars.FoundPFPs[username],
-			"DeepScanEnabled": vars.DeepScanEnabled,
+			"PFPs":            reportPFPs(username),
+			"DeepScanEnabled": vars.DeepScanEnabled || len(vars.DeepScanResults[username]) > 0,
 			"DeepScans":       vars.DeepScanResults[username],
+			"Findings":        vars.FindingInfos[username],
+			"Blocked":         vars.BlockedSites[username],
 			"Timestamp":       time.Now().Format("2006-01-02 15:04:05"),
 			"Version":         vars.Version,
+			"SitesScanned":    vars.SitesScanned,
+			"SiteFilters":     vars.SiteFilter.String(),
 		}
 
 		funcMap := template.FuncMap{
 			"index": func(m map[string]string, key string) string {
 				return m[key]
 			},
+			"getFinding": func(m map[string]vars.FindingInfo, key string) vars.FindingInfo {
+				return m[key]
+			},
+			"confidence": formatConfidence,
+			"provenance": func(chain []vars.PivotStep) string {
+				return formatProvenance(chain, username)
+			},
+			"avatarMatches": func(matches []vars.AvatarMatch) string {
+				return formatAvatarMatches(matches, username)
+			},
+			"generic": func(d *vars.DeepScanResult, field string) bool {
+				return d.FieldSources[field] == vars.FieldSourceGeneric
+			},
 			"getDeepScan": func(m map[string]vars.DeepScanResult, key string) *vars.DeepScanResult {
 				if val, ok := m[key]; ok {
 					return &val
@@ -109,10 +160,42 @@ func OutputText() {
 	for _, username := range vars.Usernames {
 		fullText := strings.ReplaceAll(header, "{U}", username)
 		fullText = strings.ReplaceAll(fullText, "{T}", time.Now().Format("2006-01-02 15:04:05"))
+		fullText += fmt.Sprintf("Sites Scanned: %d\n", vars.SitesScanned)
+		if !vars.SiteFilter.IsEmpty() {
+			fullText += fmt.Sprintf("Site Filters: %s\n", vars.SiteFilter)
+		}
+		if warc := vars.ArchiveFiles[username]; warc != nil && warc.File != "" {
+			fullText += fmt.Sprintf("Archive: %s (SHA-256 %s)\n", warc.File, warc.SHA256)
+		}
 		fullText += "--------------------------------------------------\n"
 
 		for siteName, siteURL := range vars.FoundSites[username] {
-			fullText += fmt.Sprintf("[+] %-14s => %-45s\n", siteName, siteURL)
+			info := vars.FindingInfos[username][siteName]
+			if info.Unverified {
+				fullText += fmt.Sprintf("[?] %-14s => %-45s (unverified: %s)\n", siteName, siteURL, info.UnverifiedReason)
+			} else {
+				fullText += fmt.Sprintf("[+] %-14s => %-45s\n", siteName, siteURL)
+			}
+			fullText += fmt.Sprintf("  - %-18s: %s\n", "Confidence", formatConfidence(info.Confidence))
+			if info.VisionVerdict != "" {
+				fullText += fmt.Sprintf("  - %-18s: %s\n", "Vision Verdict", info.VisionVerdict)
+			}
+			if info.Variant != nil {
+				fullText += fmt.Sprintf("  - %-18s: %s (%s of %s)\n", "Variant", username, info.Variant.Strategy, info.Variant.Seed)
+			}
+			if info.Credentials != "" {
+				fullText += fmt.Sprintf("  - %-18s: %s\n", "Logged In With", info.Credentials)
+			}
+			if len(info.Provenance) > 0 {
+				fullText += fmt.Sprintf("  - %-18s: %s\n", "Found Via", formatProvenance(info.Provenance, username))
+			}
+			if info.Avatar != nil {
+				fullText += fmt.Sprintf("  - %-18s: %s\n", "Avatar SHA-256", info.Avatar.SHA256)
+				fullText += fmt.Sprintf("  - %-18s: %s\n", "Avatar dHash", info.Avatar.DHash)
+				if len(info.Avatar.Matches) > 0 {
+					fullText += fmt.Sprintf("  - %-18s: %s\n", "Same Avatar As", formatAvatarMatches(info.Avatar.Matches, username))
+				}
+			}
 
 			if deepResult, ok := vars.DeepScanResults[username][siteName]; ok {
 				val := reflect.ValueOf(deepResult)
@@ -161,6 +244,9 @@ func OutputText() {
 							continue
 						}
 						if fieldValue != "" {
+							if deepResult.FieldSources[fieldName] == vars.FieldSourceGeneric {
+								fieldValue += " (generic)"
+							}
 							fieldName = caser.String(strings.ReplaceAll(fieldName, "_", " "))
 							fullText += fmt.Sprintf("  - %-18s: %s\n", fieldName, fieldValue)
 						}
@@ -168,8 +254,14 @@ func OutputText() {
 				}
 			}
 		}
+		for siteName, blocked := range vars.BlockedSites[username] {
+			fullText += fmt.Sprintf("[!] %-14s => %-45s (blocked: %s)\n", siteName, blocked.URL, blocked.Reason)
+		}
 		fullText += "--------------------------------------------------\n"
 		fullText += fmt.Sprintf("%d sites found for %s\n", len(vars.FoundSites[username]), username)
+		if len(vars.BlockedSites[username]) > 0 {
+			fullText += fmt.Sprintf("%d sites blocked the scan\n", len(vars.BlockedSites[username]))
+		}
 		saveResultFile("txt", username, fullText)
 	}
 }
@@ -204,6 +296,11 @@ func OutputPDF() {
 			pdf.SetTextColor(secondaryTextColor.r, secondaryTextColor.g, secondaryTextColor.b)
 			pdf.CellFormat(0, 10, "Username: "+username, "", 0, "L", false, 0, "")
 			pdf.CellFormat(0, 10, "Timestamp: "+time.Now().Format("2006-01-02 15:04:05"), "", 1, "R", false, 0, "")
+			scanned := fmt.Sprintf("Sites scanned: %d", vars.SitesScanned)
+			if !vars.SiteFilter.IsEmpty() {
+				scanned += " (" + vars.SiteFilter.String() + ")"
+			}
+			pdf.MultiCell(0, 6, scanned, "", "L", false)
 			pdf.Ln(8)
 		}
 
@@ -266,6 +363,25 @@ func OutputPDF() {
 			pdf.MultiCell(0, 5, siteURL, "R", "L", false)
 			pdf.SetX(pageMargin + siteColWidth)
 
+			info := vars.FindingInfos[username][siteName]
+			if info.Unverified {
+				drawDetailRow("Unverified", info.UnverifiedReason)
+			}
+			drawDetailRow("Confidence", formatConfidence(info.Confidence))
+			drawDetailRow("Vision Verdict", info.VisionVerdict)
+			if info.Variant != nil {
+				drawDetailRow("Variant", fmt.Sprintf("%s of %s", info.Variant.Strategy, info.Variant.Seed))
+			}
+			if len(info.Provenance) > 0 {
+				drawDetailRow("Found Via", formatProvenance(info.Provenance, username))
+			}
+			if info.Avatar != nil {
+				drawDetailRow("Avatar SHA-256", info.Avatar.SHA256)
+				if len(info.Avatar.Matches) > 0 {
+					drawDetailRow("Same Avatar As", formatAvatarMatches(info.Avatar.Matches, username))
+				}
+			}
+
 			// Deep Scan Results
 			if deepResult, ok := vars.DeepScanResults[username][siteName]; ok {
 				pdf.Ln(2)
@@ -287,7 +403,8 @@ func OutputPDF() {
 
 					caser := cases.Title(language.English)
 
-					fieldName := caser.String(strings.ReplaceAll(strings.Split(typ.Field(i).Tag.Get("json"), ",")[0], "_", " "))
+					jsonName := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+					fieldName := caser.String(strings.ReplaceAll(jsonName, "_", " "))
 					var fieldValue string
 					if actions, ok := field.Interface().([]vars.NonDefinedAction); ok {
 						// This is our special slice. Loop through each item inside it.
@@ -314,6 +431,9 @@ func OutputPDF() {
 						default:
 							continue
 						}
+						if deepResult.FieldSources[jsonName] == vars.FieldSourceGeneric {
+							fieldValue += " (generic)"
+						}
 
 						drawDetailRow(fieldName, fieldValue)
 					}
@@ -335,17 +455,23 @@ func OutputPDF() {
 	}
 }
 
+func formatConfidence(c float64) string {
+	return fmt.Sprintf("%.0f%%", c*100)
+}
+
+// userDir is the folder the results of username are saved to
+func userDir(username string) string {
+	if len(vars.Usernames) > 1 {
+		return filepath.Join(vars.OutputFolder, username)
+	}
+	return vars.OutputFolder
+}
+
 func saveResultFile(filetype string, username string, data string) {
 	// helpers.V("Output Folder: %s", vars.OutputFolder)
 
 	FileName := fmt.Sprintf("%s_results.%s", username, filetype)
-	var FilePath string
-	if len(vars.Usernames) > 1 {
-		FilePath = filepath.Join(vars.OutputFolder, username)
-		FilePath = filepath.Join(FilePath, FileName)
-	} else {
-		FilePath = filepath.Join(vars.OutputFolder, FileName)
-	}
+	FilePath := filepath.Join(userDir(username), FileName)
 	f, err := os.Create(FilePath)
 	helpers.HandleErr(err)
 
@@ -355,6 +481,99 @@ func saveResultFile(filetype string, username string, data string) {
 
 	_, err = f.Write(ByteData)
 	helpers.HandleErr(err)
+	writtenFiles = append(writtenFiles, FilePath)
 	// printer.Info("Save output file '%s'", FilePath)
 
 }
+
+// reportPFPs returns the profile picture of every site, the saved copy when there is one
+func reportPFPs(username string) map[string]string {
+	pfps := make(map[string]string, len(vars.FoundPFPs[username]))
+	for site, pfpURL := range vars.FoundPFPs[username] {
+		pfps[site] = pfpURL
+		if avatar := vars.FindingInfos[username][site].Avatar; avatar != nil && avatar.File != "" {
+			pfps[site] = avatar.File
+		}
+	}
+	return pfps
+}
+
+// SaveImages writes the profile pictures downloaded with --save-images to an images
+// folder next to the reports, and records their path in the findings.
+func SaveImages() {
+	saved := 0
+	for _, username := range vars.Usernames {
+		for site, info := range vars.FindingInfos[username] {
+			if info.Avatar == nil || len(info.Avatar.Data) == 0 {
+				continue
+			}
+			imagesDir := filepath.Join(userDir(username), "images")
+			err := os.MkdirAll(imagesDir, 0755)
+			helpers.HandleErr(err)
+
+			format := info.Avatar.Format
+			if format == "jpeg" {
+				format = "jpg"
+			}
+			fileName := site + "." + format
+			err = os.WriteFile(filepath.Join(imagesDir, fileName), info.Avatar.Data, 0644)
+			helpers.HandleErr(err)
+			writtenFiles = append(writtenFiles, filepath.Join(imagesDir, fileName))
+
+			info.Avatar.File = "images/" + fileName
+			saved++
+		}
+	}
+	if saved > 0 {
+		printer.Info("Saved %d profile pictures", saved)
+	}
+}
+
+// SaveArchives moves the WARC files written with --archive next to the reports and
+// hashes them for the JSON report.
+func SaveArchives() {
+	archived := 0
+	for _, username := range vars.Usernames {
+		warc := vars.ArchiveFiles[username]
+		if warc == nil || warc.Path == "" {
+			continue
+		}
+		fileName := username + "_archive.warc"
+		path := filepath.Join(userDir(username), fileName)
+		err := os.Rename(warc.Path, path)
+		helpers.HandleErr(err)
+
+		warc.Path = path
+		warc.File = fileName
+		file, err := hashFile(path, vars.OutputFolder)
+		helpers.HandleErr(err)
+		warc.SHA256 = file.SHA256
+		writtenFiles = append(writtenFiles, path)
+		archived += warc.Records
+	}
+	if archived > 0 {
+		printer.Info("Archived %d pages", archived)
+	}
+}
+
+// formatProvenance formats the chain of links that led to username, e.g. "alice (github.com) -> bob"
+func formatProvenance(chain []vars.PivotStep, username string) string {
+	var parts []string
+	for _, step := range chain {
+		parts = append(parts, fmt.Sprintf("%s (%s)", step.From, step.Site))
+	}
+	return strings.Join(append(parts, username), " -> ")
+}
+
+// formatAvatarMatches lists the findings with the same profile picture, naming the username only when it differs
+func formatAvatarMatches(matches []vars.AvatarMatch, username string) string {
+	var parts []string
+	for _, match := range matches {
+		if match.Username == username {
+			parts = append(parts, match.Site)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s on %s", match.Username, match.Site))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
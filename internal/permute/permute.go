@@ -0,0 +1,285 @@
+package permute
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Strategy is a way of deriving username variants from a seed.
+type Strategy string
+
+const (
+	Original   Strategy = "original"   // the seed handle itself
+	Separators Strategy = "separators" // john.doe, john-doe, john_doe, johndoe, doejohn
+	Leetspeak  Strategy = "leet"       // j0hnd03
+	Digits     Strategy = "digits"     // johndoe1, johndoe123, johndoe1994
+	Doubled    Strategy = "doubled"    // johndoee, jjohndoe
+	Initials   Strategy = "initials"   // jdoe, j.doe, johnd
+	Affixes    Strategy = "affixes"    // thejohndoe, realjohndoe, johndoe_official
+)
+
+// AllStrategies is every strategy, in the order their variants are preferred when Max cuts the list.
+var AllStrategies = []Strategy{Separators, Initials, Affixes, Digits, Leetspeak, Doubled}
+
+var (
+	separators = []string{"", ".", "_", "-"}
+	prefixes   = []string{"the", "real", "its", "iam", "im", "official", "mr"}
+	suffixes   = []string{"official", "real", "hq", "tv", "dev", "yt"}
+	digits     = []string{"1", "2", "7", "01", "12", "69", "99", "123", "007"}
+	leet       = map[rune]rune{'a': '4', 'e': '3', 'i': '1', 'o': '0', 's': '5', 't': '7'}
+)
+
+// Seed is what variants are generated from, a handle and/or a first and last name.
+type Seed struct {
+	Handle string
+	First  string
+	Last   string
+}
+
+func (s Seed) String() string {
+	if s.Handle != "" {
+		return s.Handle
+	}
+	return strings.TrimSpace(s.First + " " + s.Last)
+}
+
+// Variant is a generated username and how it was produced.
+type Variant struct {
+	Username string
+	Strategy Strategy
+	Seed     string
+}
+
+// Options control Generate. A zero Max means no limit, no Strategies means AllStrategies.
+type Options struct {
+	Strategies []Strategy
+	Max        int
+}
+
+// ParseStrategies parses a comma separated list of strategy names, "all" selects every strategy.
+func ParseStrategies(list string) ([]Strategy, error) {
+	var out []Strategy
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			return AllStrategies, nil
+		}
+		found := false
+		for _, s := range AllStrategies {
+			if string(s) == name {
+				out = append(out, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown permutation strategy %q", name)
+		}
+	}
+	return out, nil
+}
+
+// Generate returns unique variants of seed. The seed handle itself always comes first. Variants from
+// different strategies are interleaved, so cutting the list at Max keeps a mix of all of them.
+func Generate(seed Seed, opts Options) []Variant {
+	strategies := opts.Strategies
+	if len(strategies) == 0 {
+		strategies = AllStrategies
+	}
+
+	tokens := seedTokens(seed)
+	if len(tokens) == 0 {
+		return nil
+	}
+	bases := joinTokens(tokens)
+
+	seen := make(map[string]bool)
+	var out []Variant
+	add := func(username string, strategy Strategy) bool {
+		key := strings.ToLower(username)
+		if key == "" || seen[key] {
+			return true
+		}
+		if opts.Max > 0 && len(out) >= opts.Max {
+			return false
+		}
+		seen[key] = true
+		out = append(out, Variant{Username: username, Strategy: strategy, Seed: seed.String()})
+		return true
+	}
+
+	if seed.Handle != "" {
+		add(seed.Handle, Original)
+	}
+
+	lists := make([][]string, len(strategies))
+	for i, strategy := range strategies {
+		lists[i] = generate(strategy, tokens, bases)
+	}
+
+	for i := 0; ; i++ {
+		more := false
+		for s, list := range lists {
+			if i >= len(list) {
+				continue
+			}
+			more = true
+			if !add(list[i], strategies[s]) {
+				return out
+			}
+		}
+		if !more {
+			return out
+		}
+	}
+}
+
+func generate(strategy Strategy, tokens []string, bases []string) []string {
+	var out []string
+	switch strategy {
+	case Separators:
+		out = append(out, bases...)
+		if len(tokens) > 1 {
+			reversed := make([]string, len(tokens))
+			for i, t := range tokens {
+				reversed[len(tokens)-1-i] = t
+			}
+			out = append(out, joinTokens(reversed)...)
+		}
+	case Initials:
+		if len(tokens) < 2 {
+			return nil
+		}
+		first, last := tokens[0], tokens[len(tokens)-1]
+		for _, sep := range separators {
+			out = append(out, initial(first)+sep+last, first+sep+initial(last), last+sep+initial(first))
+		}
+	case Affixes:
+		for _, base := range bases[:1] {
+			for _, p := range prefixes {
+				out = append(out, p+base, p+"_"+base)
+			}
+			for _, s := range suffixes {
+				out = append(out, base+s, base+"_"+s)
+			}
+		}
+	case Digits:
+		base := bases[0]
+		for _, d := range digits {
+			out = append(out, base+d)
+		}
+		for year := time.Now().Year(); year >= 1970; year-- {
+			out = append(out, base+fmt.Sprint(year), base+fmt.Sprintf("%02d", year%100))
+		}
+	case Leetspeak:
+		for _, base := range bases {
+			out = append(out, leetAll(base))
+			for _, from := range "aeiost" {
+				if replaced := strings.ReplaceAll(base, string(from), string(leet[from])); replaced != base {
+					out = append(out, replaced)
+				}
+			}
+		}
+	case Doubled:
+		base := bases[0]
+		runes := []rune(base)
+		for i, r := range runes {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			out = append(out, string(runes[:i+1])+string(runes[i:]))
+		}
+		if collapsed := collapseDoubles(base); collapsed != base {
+			out = append(out, collapsed)
+		}
+	}
+	return out
+}
+
+// seedTokens splits the seed into lower case words: the first and last name, or
+// the handle split on separators and camelCase.
+func seedTokens(seed Seed) []string {
+	var tokens []string
+	if seed.First != "" || seed.Last != "" {
+		for _, name := range []string{seed.First, seed.Last} {
+			tokens = append(tokens, strings.Fields(cleanToken(name))...)
+		}
+		return tokens
+	}
+
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, strings.ToLower(string(current)))
+			current = nil
+		}
+	}
+	runes := []rune(seed.Handle)
+	for i, r := range runes {
+		switch {
+		case r == '.' || r == '_' || r == '-' || unicode.IsSpace(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+			current = append(current, r)
+		default:
+			current = append(current, r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func cleanToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// joinTokens joins the tokens with every separator, the separator-less form first.
+func joinTokens(tokens []string) []string {
+	if len(tokens) == 1 {
+		return tokens
+	}
+	out := make([]string, 0, len(separators))
+	for _, sep := range separators {
+		out = append(out, strings.Join(tokens, sep))
+	}
+	return out
+}
+
+func initial(s string) string {
+	r, _ := utf8.DecodeRuneInString(s)
+	return string(r)
+}
+
+func leetAll(s string) string {
+	return strings.Map(func(r rune) rune {
+		if l, ok := leet[r]; ok {
+			return l
+		}
+		return r
+	}, s)
+}
+
+func collapseDoubles(s string) string {
+	var b strings.Builder
+	var prev rune
+	for i, r := range s {
+		if i > 0 && r == prev && unicode.IsLetter(r) {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
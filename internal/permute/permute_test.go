@@ -0,0 +1,146 @@
+package permute
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func usernames(variants []Variant) []string {
+	out := make([]string, len(variants))
+	for i, v := range variants {
+		out[i] = v.Username
+	}
+	return out
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    Seed
+		opts    Options
+		want    []string // must all be generated
+		notWant []string
+		count   int // exact amount of variants, when set
+	}{
+		{
+			name:  "max caps the list",
+			seed:  Seed{Handle: "johndoe"},
+			opts:  Options{Max: 5},
+			want:  []string{"johndoe"},
+			count: 5,
+		},
+		{
+			name:  "max keeps a mix of strategies",
+			seed:  Seed{Handle: "john.doe"},
+			opts:  Options{Strategies: []Strategy{Separators, Digits}, Max: 4},
+			want:  []string{"john.doe", "johndoe", "johndoe1"},
+			count: 4,
+		},
+		{
+			name:    "handle split on separators and camelCase",
+			seed:    Seed{Handle: "JohnDoe"},
+			opts:    Options{Strategies: []Strategy{Separators, Initials}},
+			want:    []string{"JohnDoe", "john.doe", "john_doe", "john-doe", "doejohn", "jdoe", "johnd"},
+			notWant: []string{"johndoe"}, // same as the handle, only differently cased
+		},
+		{
+			name:    "name seeds",
+			seed:    Seed{First: "Jöhn", Last: "O'Doe"},
+			opts:    Options{Strategies: []Strategy{Separators, Initials}},
+			want:    []string{"jöhnodoe", "jöhn.odoe", "odoejöhn", "jodoe", "j.odoe", "odoej"},
+			notWant: []string{"Jöhn", "O'Doe"},
+		},
+		{
+			name:  "single word has no initials",
+			seed:  Seed{Handle: "alice"},
+			opts:  Options{Strategies: []Strategy{Initials}},
+			want:  []string{"alice"},
+			count: 1,
+		},
+		{
+			name:  "empty seed",
+			seed:  Seed{},
+			count: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := usernames(Generate(tt.seed, tt.opts))
+			for _, want := range tt.want {
+				if !slices.Contains(got, want) {
+					t.Errorf("%q is missing from %v", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if slices.Contains(got, notWant) {
+					t.Errorf("%q should not be generated, got %v", notWant, got)
+				}
+			}
+			if tt.count > 0 || len(tt.want) == 0 {
+				if len(got) != tt.count {
+					t.Errorf("got %d variants %v, want %d", len(got), got, tt.count)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateDedupe(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, v := range Generate(Seed{Handle: "sasha.sass"}, Options{}) {
+		key := strings.ToLower(v.Username)
+		if seen[key] {
+			t.Errorf("%q generated twice", v.Username)
+		}
+		seen[key] = true
+	}
+}
+
+func TestGenerateStrategies(t *testing.T) {
+	variants := Generate(Seed{Handle: "john_doe"}, Options{Strategies: []Strategy{Leetspeak}})
+	if len(variants) == 0 || variants[0].Username != "john_doe" || variants[0].Strategy != Original {
+		t.Fatalf("the handle should come first as %s, got %v", Original, variants)
+	}
+	for _, v := range variants[1:] {
+		if v.Strategy != Leetspeak {
+			t.Errorf("%q comes from %s, want only %s", v.Username, v.Strategy, Leetspeak)
+		}
+		if v.Seed != "john_doe" {
+			t.Errorf("%q has seed %q, want john_doe", v.Username, v.Seed)
+		}
+	}
+}
+
+func TestParseStrategies(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []Strategy
+		wantErr string
+	}{
+		{list: "all", want: AllStrategies},
+		{list: "leet, Digits", want: []Strategy{Leetspeak, Digits}},
+		{list: "initials,,", want: []Strategy{Initials}},
+		{list: "", want: nil},
+		{list: "digits,typos", wantErr: `unknown permutation strategy "typos"`},
+		{list: "original", wantErr: `unknown permutation strategy "original"`},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStrategies(tt.list)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseStrategies(%q) error = %v, want %q", tt.list, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseStrategies(%q) error = %v", tt.list, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseStrategies(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults of CheckConfig. The default URL is a third party service, which sees the
+// address of every proxy checked.
+const (
+	DefaultCheckURL       = "https://ipinfo.io/ip"
+	DefaultExpectedStatus = http.StatusOK
+	DefaultCheckTimeout   = 10 * time.Second
+)
+
+// CheckConfig is how proxies are health checked.
+type CheckConfig struct {
+	URL            string // requested through the proxy
+	ExpectedStatus int
+	Timeout        time.Duration
+	// Leak check: IPURL answers with the IP it sees the request come from. It has no
+	// default, since it is sent the real IP. The exit IP of every proxy is recorded, and
+	// a proxy whose exit IP is RealIP, the IP of a direct request, is rejected.
+	LeakCheck bool
+	IPURL     string
+	RealIP    string
+}
+
+func (c CheckConfig) withDefaults() CheckConfig {
+	if c.URL == "" {
+		c.URL = DefaultCheckURL
+	}
+	if c.ExpectedStatus == 0 {
+		c.ExpectedStatus = DefaultExpectedStatus
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultCheckTimeout
+	}
+	return c
+}
+
+// ErrNoIPURL is returned by the leak check when no IPURL is set.
+var ErrNoIPURL = errors.New("the leak check needs an IP echo endpoint (\"ip_url\" in config.json)")
+
+// NewChecker returns a Checker that requests config.URL through the proxy and expects
+// config.ExpectedStatus, then runs the leak check if it is enabled.
+func NewChecker(config CheckConfig) Checker {
+	config = config.withDefaults()
+	return func(ctx context.Context, proxy *url.URL) (string, error) {
+		client := newClient(http.ProxyURL(proxy), config.Timeout)
+		defer client.CloseIdleConnections()
+
+		status, body, err := get(ctx, client, config.URL)
+		if err != nil {
+			return "", err
+		}
+		if status != config.ExpectedStatus {
+			return "", fmt.Errorf("%s returned status %d, expected %d", config.URL, status, config.ExpectedStatus)
+		}
+		if !config.LeakCheck {
+			return "", nil
+		}
+		if config.IPURL == "" {
+			return "", ErrNoIPURL
+		}
+
+		if config.IPURL != config.URL {
+			if status, body, err = get(ctx, client, config.IPURL); err != nil {
+				return "", err
+			}
+		}
+		exitIP, err := parseIP(config.IPURL, status, body)
+		if err != nil {
+			return "", err
+		}
+		if exitIP == config.RealIP {
+			return exitIP, fmt.Errorf("leaks your real IP (%s)", exitIP)
+		}
+		return exitIP, nil
+	}
+}
+
+// DirectIP returns the IP config.IPURL sees a request without a proxy come from, the
+// RealIP of the leak check.
+func DirectIP(ctx context.Context, config CheckConfig) (string, error) {
+	config = config.withDefaults()
+	if config.IPURL == "" {
+		return "", ErrNoIPURL
+	}
+	client := newClient(nil, config.Timeout)
+	defer client.CloseIdleConnections()
+
+	status, body, err := get(ctx, client, config.IPURL)
+	if err != nil {
+		return "", err
+	}
+	return parseIP(config.IPURL, status, body)
+}
+
+func newClient(proxy func(*http.Request) (*url.URL, error), timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{Proxy: proxy},
+		Timeout:   timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func get(ctx context.Context, client *http.Client, target string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if err != nil {
+		return 0, "", err
+	}
+	return res.StatusCode, string(body), nil
+}
+
+// parseIP reads the IP an IP echo endpoint answered with.
+func parseIP(target string, status int, body string) (string, error) {
+	if status != http.StatusOK {
+		return "", fmt.Errorf("%s returned status %d", target, status)
+	}
+	ip := net.ParseIP(strings.TrimSpace(body))
+	if ip == nil {
+		return "", fmt.Errorf("%s did not answer with an IP address", target)
+	}
+	return ip.String(), nil
+}
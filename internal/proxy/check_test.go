@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newIPEcho answers with the IP a request comes from: X-Exit-IP when a proxy stand-in
+// set it, the remote address otherwise.
+func newIPEcho(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.Header.Get("X-Exit-IP")
+		if ip == "" {
+			ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+		}
+		_, _ = fmt.Fprintln(w, ip)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestCheckerExpectedStatus(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer target.Close()
+	p := mustParse(t, newHTTPProxy(t).URL())
+
+	check := NewChecker(CheckConfig{URL: target.URL + "/health", ExpectedStatus: http.StatusNoContent, Timeout: 5 * time.Second})
+	if _, err := check(context.Background(), p); err != nil {
+		t.Fatalf("check with the expected status failed: %v", err)
+	}
+
+	check = NewChecker(CheckConfig{URL: target.URL + "/missing", ExpectedStatus: http.StatusOK, Timeout: 5 * time.Second})
+	if _, err := check(context.Background(), p); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Fatalf("check with the wrong status = %v, want a status error", err)
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+
+	check := NewChecker(CheckConfig{URL: slow.URL, Timeout: 100 * time.Millisecond})
+	start := time.Now()
+	if _, err := check(context.Background(), mustParse(t, newHTTPProxy(t).URL())); err == nil {
+		t.Fatal("check against a slow endpoint succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("check took %v, the timeout is 100ms", elapsed)
+	}
+}
+
+func TestLeakCheck(t *testing.T) {
+	echo := newIPEcho(t)
+	config := CheckConfig{URL: echo.URL, IPURL: echo.URL, Timeout: 5 * time.Second, LeakCheck: true}
+
+	realIP, err := DirectIP(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if realIP != "127.0.0.1" {
+		t.Fatalf("DirectIP() = %q, want 127.0.0.1", realIP)
+	}
+	config.RealIP = realIP
+
+	// The HTTP stand-in presents another exit IP, the SOCKS one connects from ours
+	httpP := newHTTPProxy(t)
+	httpP.exitIP = "203.0.113.7"
+	socksP := newSOCKSProxy(t)
+
+	pool, err := NewPool([]string{httpP.URL(), socksP.URL()}, RoundRobin, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := pool.HealthCheck(context.Background(), NewChecker(config), 2)
+
+	if len(failed) != 1 || failed[0].Proxy != socksP.URL() || !strings.Contains(failed[0].LastError.Error(), "leaks your real IP") {
+		t.Fatalf("failed = %+v, want the SOCKS proxy rejected as leaking", failed)
+	}
+	for _, stats := range pool.Stats() {
+		want := "203.0.113.7"
+		if stats.Proxy == socksP.URL() {
+			want = "127.0.0.1"
+		}
+		if stats.ExitIP != want {
+			t.Errorf("%s: exit IP %q, want %q", stats.Proxy, stats.ExitIP, want)
+		}
+	}
+}
+
+func TestLeakCheckSeparateIPURL(t *testing.T) {
+	health := newTarget(t)
+	echo := newIPEcho(t)
+	httpP := newHTTPProxy(t)
+	httpP.exitIP = "198.51.100.20"
+
+	check := NewChecker(CheckConfig{URL: health.URL, IPURL: echo.URL, Timeout: 5 * time.Second, LeakCheck: true, RealIP: "127.0.0.1"})
+	exitIP, err := check(context.Background(), mustParse(t, httpP.URL()))
+	if err != nil || exitIP != "198.51.100.20" {
+		t.Fatalf("check = %q, %v, want the exit IP from the IP URL", exitIP, err)
+	}
+	if httpP.hits.Load() != 2 {
+		t.Fatalf("proxy relayed %d requests, want the health check and the IP lookup", httpP.hits.Load())
+	}
+
+	// An IP URL that doesn't answer with an IP fails the check
+	check = NewChecker(CheckConfig{URL: health.URL, IPURL: health.URL, Timeout: 5 * time.Second, LeakCheck: true, RealIP: "127.0.0.1"})
+	if _, err := check(context.Background(), mustParse(t, httpP.URL())); err == nil || !strings.Contains(err.Error(), "did not answer with an IP") {
+		t.Fatalf("check against a non-IP answer = %v", err)
+	}
+}
+
+func TestLeakCheckNeedsIPURL(t *testing.T) {
+	health := newTarget(t)
+	config := CheckConfig{URL: health.URL, Timeout: 5 * time.Second, LeakCheck: true}
+
+	// Without an IP URL, nothing may be sent the real IP, not even the health check URL
+	if _, err := DirectIP(context.Background(), config); !errors.Is(err, ErrNoIPURL) {
+		t.Fatalf("DirectIP() without an IP URL = %v, want ErrNoIPURL", err)
+	}
+	httpP := newHTTPProxy(t)
+	if _, err := NewChecker(config)(context.Background(), mustParse(t, httpP.URL())); !errors.Is(err, ErrNoIPURL) {
+		t.Fatalf("check without an IP URL = %v, want ErrNoIPURL", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Package proxy spreads requests over a pool of HTTP and SOCKS5 proxies, tracks how each
+// one performs and drops the ones that stop working.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Strategy is how the next proxy is picked.
+type Strategy string
+
+const (
+	RoundRobin   Strategy = "round-robin"
+	Random       Strategy = "random"
+	LeastLatency Strategy = "least-latency" // lowest average latency, untried proxies first
+)
+
+// Strategies lists every Strategy, for flag help.
+var Strategies = []Strategy{RoundRobin, Random, LeastLatency}
+
+// ErrNoProxies is returned when every proxy of the pool has been evicted.
+var ErrNoProxies = errors.New("no working proxies left")
+
+// Weight of the newest sample in the average latency
+const latencySmoothing = 0.3
+
+// Stats of a single proxy.
+type Stats struct {
+	Proxy               string
+	Successes           int
+	Failures            int
+	ConsecutiveFailures int
+	Latency             time.Duration // moving average of successful requests
+	Evicted             bool
+	LastError           error
+	ExitIP              string // with the leak check
+}
+
+type entry struct {
+	url   *url.URL
+	stats Stats
+}
+
+// Pool is a set of proxies. It is safe for concurrent use.
+type Pool struct {
+	// Consecutive failures after which a proxy is evicted, 0 never evicts
+	MaxFailures int
+	// Called when a proxy is evicted, without the pool locked
+	OnEvict func(stats Stats)
+
+	mu       sync.Mutex
+	entries  []*entry // in the order they were given, evicted ones included
+	strategy Strategy
+	next     int
+	rand     *rand.Rand
+}
+
+// NewPool parses the proxy URLs (http, https, socks5 or socks5h).
+func NewPool(proxies []string, strategy Strategy, maxFailures int) (*Pool, error) {
+	switch strategy {
+	case RoundRobin, Random, LeastLatency:
+	default:
+		return nil, fmt.Errorf("unknown proxy strategy %q", strategy)
+	}
+
+	p := &Pool{
+		MaxFailures: maxFailures,
+		strategy:    strategy,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, raw := range proxies {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", raw)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", raw, u.Scheme)
+		}
+		p.entries = append(p.entries, &entry{url: u, stats: Stats{Proxy: raw}})
+	}
+	return p, nil
+}
+
+// Checker tests a single proxy, returning its exit IP if it was checked.
+type Checker func(ctx context.Context, proxy *url.URL) (exitIP string, err error)
+
+// HealthCheck runs check against every proxy, concurrency at a time, recording the
+// latency of those that pass and evicting those that fail. It returns the stats of
+// the failed proxies.
+func (p *Pool) HealthCheck(ctx context.Context, check Checker, concurrency int) []Stats {
+	p.mu.Lock()
+	entries := append([]*entry(nil), p.entries...)
+	p.mu.Unlock()
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var failedMu sync.Mutex
+	var failed []Stats
+	for _, e := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(e *entry) {
+			defer func() { <-sem; wg.Done() }()
+			start := time.Now()
+			exitIP, err := check(ctx, e.url)
+			p.mu.Lock()
+			e.stats.ExitIP = exitIP
+			p.mu.Unlock()
+			if err == nil {
+				p.Report(e.url.String(), time.Since(start), nil)
+				return
+			}
+			p.mu.Lock()
+			e.stats.Failures++
+			e.stats.ConsecutiveFailures++
+			e.stats.LastError = err
+			e.stats.Evicted = true
+			stats := e.stats
+			p.mu.Unlock()
+
+			failedMu.Lock()
+			failed = append(failed, stats)
+			failedMu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Proxy < failed[j].Proxy })
+	return failed
+}
+
+// Pick returns the next proxy to use.
+func (p *Pool) Pick() (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	live := p.live()
+	if len(live) == 0 {
+		return nil, ErrNoProxies
+	}
+
+	switch p.strategy {
+	case Random:
+		return live[p.rand.Intn(len(live))].url, nil
+	case LeastLatency:
+		best := live[0]
+		for _, e := range live[1:] {
+			if e.stats.Latency < best.stats.Latency {
+				best = e
+			}
+		}
+		return best.url, nil
+	default:
+		e := live[p.next%len(live)]
+		p.next++
+		return e.url, nil
+	}
+}
+
+// live returns the proxies that haven't been evicted. p.mu must be held.
+func (p *Pool) live() []*entry {
+	var live []*entry
+	for _, e := range p.entries {
+		if !e.stats.Evicted {
+			live = append(live, e)
+		}
+	}
+	return live
+}
+
+// Report records the outcome of a request made through proxy. A nil err is a success.
+func (p *Pool) Report(proxy string, latency time.Duration, err error) {
+	p.mu.Lock()
+	var evicted *Stats
+	for _, e := range p.entries {
+		if e.url.String() != proxy {
+			continue
+		}
+		if err == nil {
+			e.stats.Successes++
+			e.stats.ConsecutiveFailures = 0
+			if e.stats.Latency == 0 {
+				e.stats.Latency = latency
+			} else {
+				e.stats.Latency += time.Duration(latencySmoothing * float64(latency-e.stats.Latency))
+			}
+			break
+		}
+		e.stats.Failures++
+		e.stats.ConsecutiveFailures++
+		e.stats.LastError = err
+		if !e.stats.Evicted && p.MaxFailures > 0 && e.stats.ConsecutiveFailures >= p.MaxFailures {
+			e.stats.Evicted = true
+			stats := e.stats
+			evicted = &stats
+		}
+		break
+	}
+	onEvict := p.OnEvict
+	p.mu.Unlock()
+
+	if evicted != nil && onEvict != nil {
+		onEvict(*evicted)
+	}
+}
+
+// IsLive reports whether proxy is in the pool and hasn't been evicted.
+func (p *Pool) IsLive(proxy string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.live() {
+		if e.url.String() == proxy {
+			return true
+		}
+	}
+	return false
+}
+
+// Len is the number of proxies that haven't been evicted.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.live())
+}
+
+// Stats returns the stats of every proxy, evicted ones included.
+func (p *Pool) Stats() []Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]Stats, len(p.entries))
+	for i, e := range p.entries {
+		stats[i] = e.stats
+	}
+	return stats
+}
+
+type contextKey struct{}
+
+// WithProxy returns a context whose requests go through proxy, see ProxyFunc.
+func WithProxy(ctx context.Context, proxy *url.URL) context.Context {
+	return context.WithValue(ctx, contextKey{}, proxy)
+}
+
+// FromContext returns the proxy set with WithProxy.
+func FromContext(ctx context.Context) (*url.URL, bool) {
+	proxy, ok := ctx.Value(contextKey{}).(*url.URL)
+	return proxy, ok && proxy != nil
+}
+
+// ProxyFunc is an http.Transport Proxy function that sends each request through the
+// proxy in its context, or a freshly picked one. Requests fail once the pool is empty,
+// they are never sent directly.
+func (p *Pool) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if proxy, ok := FromContext(req.Context()); ok {
+			return proxy, nil
+		}
+		return p.Pick()
+	}
+}
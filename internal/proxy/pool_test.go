@@ -0,0 +1,334 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// target is the site requests are proxied to.
+func newTarget(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// httpProxy is a forward HTTP proxy stand-in that counts the requests it relays.
+type httpProxy struct {
+	*httptest.Server
+	hits atomic.Int32
+	// Sent to the target as X-Exit-IP, see newIPEcho
+	exitIP string
+}
+
+func newHTTPProxy(t *testing.T) *httpProxy {
+	t.Helper()
+	p := &httpProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.hits.Add(1)
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, r.URL.String(), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p.exitIP != "" {
+			req.Header.Set("X-Exit-IP", p.exitIP)
+		}
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *httpProxy) URL() string { return p.Server.URL }
+
+// socksProxy is a SOCKS5 stand-in (no authentication, CONNECT only) that counts the
+// connections it relays.
+type socksProxy struct {
+	ln   net.Listener
+	hits atomic.Int32
+}
+
+func newSOCKSProxy(t *testing.T) *socksProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &socksProxy{ln: ln}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *socksProxy) URL() string { return "socks5://" + p.ln.Addr().String() }
+
+func (p *socksProxy) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	// Greeting: version, methods
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, head[1])); err != nil {
+		return
+	}
+	_, _ = conn.Write([]byte{5, 0})
+
+	// Request: version, command, reserved, address type, address, port
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		addr := make([]byte, 4)
+		_, _ = io.ReadFull(conn, addr)
+		host = net.IP(addr).String()
+	case 3:
+		n := make([]byte, 1)
+		_, _ = io.ReadFull(conn, n)
+		name := make([]byte, n[0])
+		_, _ = io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return
+	}
+	port := binary.BigEndian.Uint16(portBytes)
+
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+	p.hits.Add(1)
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+// deadProxy returns the URL of a port nothing listens on.
+func deadProxy(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return "http://" + addr
+}
+
+func fetch(t *testing.T, client *http.Client, ctx context.Context, target string) error {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	return nil
+}
+
+func TestHealthCheckEvictsDeadProxies(t *testing.T) {
+	target := newTarget(t)
+	httpP, socksP := newHTTPProxy(t), newSOCKSProxy(t)
+	dead := deadProxy(t)
+
+	pool, err := NewPool([]string{httpP.URL(), dead, socksP.URL()}, RoundRobin, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := pool.HealthCheck(context.Background(), NewChecker(CheckConfig{URL: target.URL, Timeout: 5 * time.Second}), 3)
+
+	if len(failed) != 1 || failed[0].Proxy != dead || failed[0].LastError == nil {
+		t.Fatalf("failed = %+v, want only %s", failed, dead)
+	}
+	if pool.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", pool.Len())
+	}
+	for _, stats := range pool.Stats() {
+		if stats.Proxy == dead {
+			continue
+		}
+		if stats.Successes != 1 || stats.Latency <= 0 {
+			t.Errorf("%s: stats = %+v, want one success with a latency", stats.Proxy, stats)
+		}
+	}
+	if httpP.hits.Load() != 1 || socksP.hits.Load() != 1 {
+		t.Errorf("hits: http %d, socks %d, want 1 each", httpP.hits.Load(), socksP.hits.Load())
+	}
+}
+
+func TestProxyFuncRoutesThroughContextProxy(t *testing.T) {
+	target := newTarget(t)
+	httpP, socksP := newHTTPProxy(t), newSOCKSProxy(t)
+
+	pool, err := NewPool([]string{httpP.URL(), socksP.URL()}, RoundRobin, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: pool.ProxyFunc(), DisableKeepAlives: true}}
+
+	socksURL, _ := url.Parse(socksP.URL())
+	for i := 0; i < 3; i++ {
+		if err := fetch(t, client, WithProxy(context.Background(), socksURL), target.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if socksP.hits.Load() != 3 || httpP.hits.Load() != 0 {
+		t.Fatalf("hits: http %d, socks %d, want 0 and 3", httpP.hits.Load(), socksP.hits.Load())
+	}
+
+	// Without a proxy in the context, the pool picks one
+	for i := 0; i < 2; i++ {
+		if err := fetch(t, client, context.Background(), target.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if socksP.hits.Load() != 4 || httpP.hits.Load() != 1 {
+		t.Fatalf("hits: http %d, socks %d, want 1 and 4", httpP.hits.Load(), socksP.hits.Load())
+	}
+}
+
+func TestReportEvictsAfterConsecutiveFailures(t *testing.T) {
+	pool, err := NewPool([]string{"http://a.example:8080", "socks5://b.example:1080"}, RoundRobin, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var evicted []string
+	pool.OnEvict = func(stats Stats) { evicted = append(evicted, stats.Proxy) }
+
+	failure := errors.New("connection reset")
+	pool.Report("http://a.example:8080", 0, failure)
+	pool.Report("http://a.example:8080", 0, failure)
+	pool.Report("http://a.example:8080", 10*time.Millisecond, nil) // resets the streak
+	pool.Report("http://a.example:8080", 0, failure)
+	pool.Report("http://a.example:8080", 0, failure)
+	if pool.Len() != 2 || len(evicted) != 0 {
+		t.Fatalf("evicted %v before 3 failures in a row", evicted)
+	}
+
+	pool.Report("http://a.example:8080", 0, failure)
+	if pool.Len() != 1 || len(evicted) != 1 || evicted[0] != "http://a.example:8080" {
+		t.Fatalf("Len() = %d, evicted = %v, want a.example evicted", pool.Len(), evicted)
+	}
+	for i := 0; i < 3; i++ {
+		if picked, _ := pool.Pick(); picked.Host != "b.example:1080" {
+			t.Fatalf("Pick() = %s after eviction", picked)
+		}
+	}
+	if pool.IsLive("http://a.example:8080") || !pool.IsLive("socks5://b.example:1080") || pool.IsLive("http://c.example:8080") {
+		t.Fatal("IsLive() should only be true for b.example, the proxy left in the pool")
+	}
+
+	for i := 0; i < 3; i++ {
+		pool.Report("socks5://b.example:1080", 0, failure)
+	}
+	if _, err := pool.Pick(); !errors.Is(err, ErrNoProxies) {
+		t.Fatalf("Pick() on an empty pool = %v, want ErrNoProxies", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if _, err := pool.ProxyFunc()(req); !errors.Is(err, ErrNoProxies) {
+		t.Fatalf("ProxyFunc on an empty pool = %v, want ErrNoProxies instead of a direct request", err)
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	proxies := []string{"http://a.example:1", "http://b.example:2", "http://c.example:3"}
+
+	t.Run("round-robin", func(t *testing.T) {
+		pool, _ := NewPool(proxies, RoundRobin, 0)
+		var got []string
+		for i := 0; i < 6; i++ {
+			picked, _ := pool.Pick()
+			got = append(got, picked.Hostname())
+		}
+		want := "[a.example b.example c.example a.example b.example c.example]"
+		if fmt.Sprint(got) != want {
+			t.Fatalf("picked %v, want %s", got, want)
+		}
+	})
+
+	t.Run("least-latency", func(t *testing.T) {
+		pool, _ := NewPool(proxies, LeastLatency, 0)
+		pool.Report("http://a.example:1", 300*time.Millisecond, nil)
+		pool.Report("http://b.example:2", 50*time.Millisecond, nil)
+		pool.Report("http://c.example:3", 120*time.Millisecond, nil)
+		for i := 0; i < 3; i++ {
+			if picked, _ := pool.Pick(); picked.Hostname() != "b.example" {
+				t.Fatalf("Pick() = %s, want the fastest proxy", picked)
+			}
+		}
+		// b slows down, its average moves past c's
+		for i := 0; i < 10; i++ {
+			pool.Report("http://b.example:2", time.Second, nil)
+		}
+		if picked, _ := pool.Pick(); picked.Hostname() != "c.example" {
+			t.Fatalf("Pick() = %s, want c.example once b.example is slower", picked)
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		pool, _ := NewPool(proxies, Random, 1)
+		pool.Report("http://a.example:1", 0, errors.New("down"))
+		seen := make(map[string]bool)
+		for i := 0; i < 200; i++ {
+			picked, _ := pool.Pick()
+			seen[picked.Hostname()] = true
+		}
+		if seen["a.example"] || !seen["b.example"] || !seen["c.example"] {
+			t.Fatalf("picked %v, want b and c but never the evicted a", seen)
+		}
+	})
+}
+
+func TestNewPoolRejectsInvalidProxies(t *testing.T) {
+	for _, proxies := range [][]string{{"ftp://a.example:21"}, {"not a url"}, {"http://"}} {
+		if _, err := NewPool(proxies, Random, 3); err == nil {
+			t.Errorf("NewPool(%q) succeeded", proxies)
+		}
+	}
+	if _, err := NewPool(nil, Strategy("fastest"), 3); err == nil {
+		t.Error("NewPool with an unknown strategy succeeded")
+	}
+}
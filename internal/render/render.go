@@ -0,0 +1,165 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const DefaultTimeout = 20 * time.Second
+
+// Longest we wait for the network to go idle when no wait selector is given
+const idleFallback = 5 * time.Second
+
+// Renderer fetches pages through a headless Chromium over the DevTools protocol, so
+// content rendered by JavaScript ends up in the returned HTML.
+type Renderer struct {
+	allocCancel   context.CancelFunc
+	browserCtx    context.Context
+	browserCancel context.CancelFunc
+}
+
+// Options for a single Fetch.
+type Options struct {
+	WaitSelector string            // CSS selector to wait for, waits for network idle when empty
+	UserAgent    string            // optional User-Agent override
+	Headers      map[string]string // extra request headers
+	Cookies      []*http.Cookie    // set for the page's URL before loading it
+	Timeout      time.Duration     // defaults to DefaultTimeout
+}
+
+// Page is a rendered page.
+type Page struct {
+	HTML       string
+	URL        string // final URL after redirects
+	StatusCode int
+}
+
+// New starts a headless Chromium. proxy may be empty.
+func New(ctx context.Context, proxy string) (*Renderer, error) {
+	opts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	if proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(proxy))
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+
+	// Run with no actions to start the browser now instead of on the first Fetch
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserCancel()
+		allocCancel()
+		return nil, fmt.Errorf("could not start headless Chromium (is Chrome or Chromium installed?): %w", err)
+	}
+
+	return &Renderer{
+		allocCancel:   allocCancel,
+		browserCtx:    browserCtx,
+		browserCancel: browserCancel,
+	}, nil
+}
+
+// Fetch loads url in a new tab and returns the DOM once it is rendered.
+func (r *Renderer) Fetch(ctx context.Context, url string, opts Options) (*Page, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	tabCtx, tabCancel := chromedp.NewContext(r.browserCtx)
+	defer tabCancel()
+	tabCtx, timeoutCancel := context.WithTimeout(tabCtx, opts.Timeout)
+	defer timeoutCancel()
+
+	// Stop the tab if the caller gives up
+	stop := context.AfterFunc(ctx, tabCancel)
+	defer stop()
+
+	var (
+		mu       sync.Mutex
+		status   int
+		loaderID string
+		idle     = make(chan struct{})
+		idleOnce sync.Once
+	)
+	chromedp.ListenTarget(tabCtx, func(ev any) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e := ev.(type) {
+		case *network.EventResponseReceived:
+			// The first document response is the page itself, redirects are not reported here
+			if e.Type == network.ResourceTypeDocument && status == 0 {
+				status = int(e.Response.Status)
+				loaderID = string(e.LoaderID)
+			}
+		case *page.EventLifecycleEvent:
+			if e.Name == "networkIdle" && loaderID != "" && string(e.LoaderID) == loaderID {
+				idleOnce.Do(func() { close(idle) })
+			}
+		}
+	})
+
+	actions := []chromedp.Action{
+		network.Enable(),
+		page.Enable(),
+		page.SetLifecycleEventsEnabled(true),
+	}
+	if opts.UserAgent != "" {
+		actions = append(actions, emulation.SetUserAgentOverride(opts.UserAgent))
+	}
+	for _, cookie := range opts.Cookies {
+		actions = append(actions, network.SetCookie(cookie.Name, cookie.Value).WithURL(url))
+	}
+	if len(opts.Headers) > 0 {
+		headers := make(network.Headers, len(opts.Headers))
+		for name, value := range opts.Headers {
+			headers[name] = value
+		}
+		actions = append(actions, network.SetExtraHTTPHeaders(headers))
+	}
+	actions = append(actions, chromedp.Navigate(url))
+
+	if opts.WaitSelector != "" {
+		actions = append(actions, chromedp.WaitReady(opts.WaitSelector, chromedp.ByQuery))
+	} else {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			select {
+			case <-idle:
+			case <-time.After(idleFallback):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		}))
+	}
+
+	var html, finalURL string
+	actions = append(actions,
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+		chromedp.Location(&finalURL),
+	)
+
+	if err := chromedp.Run(tabCtx, actions...); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("rendering %s timed out after %v", url, opts.Timeout)
+		}
+		return nil, fmt.Errorf("rendering %s: %w", url, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return &Page{HTML: html, URL: finalURL, StatusCode: status}, nil
+}
+
+// Close shuts down the browser.
+func (r *Renderer) Close() {
+	r.browserCancel()
+	r.allocCancel()
+}
@@ -0,0 +1,109 @@
+package render
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+const spaPage = `<!doctype html>
+<html>
+<head><title>Profile</title></head>
+<body>
+<div id="app"></div>
+<script>
+setTimeout(function () {
+	document.getElementById("app").innerHTML = '<h1 class="profile-name">octocat</h1>';
+}, 200);
+</script>
+</body>
+</html>`
+
+func requireBrowser(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"headless-shell", "headless_shell", "chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return
+		}
+	}
+	t.Skip("no Chrome or Chromium found in PATH")
+}
+
+func newSPAServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/octocat":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(spaPage))
+		case "/old":
+			http.Redirect(w, r, "/octocat", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newRenderer(t *testing.T) *Renderer {
+	t.Helper()
+	r, err := New(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestFetchWaitsForSelector(t *testing.T) {
+	requireBrowser(t)
+	srv := newSPAServer(t)
+	r := newRenderer(t)
+
+	page, err := r.Fetch(context.Background(), srv.URL+"/old", Options{WaitSelector: "h1.profile-name", Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.HTML, "octocat") {
+		t.Fatalf("rendered HTML does not contain the JavaScript content:\n%s", page.HTML)
+	}
+	if page.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", page.StatusCode)
+	}
+	if page.URL != srv.URL+"/octocat" {
+		t.Fatalf("expected the final URL after the redirect, got %s", page.URL)
+	}
+}
+
+func TestFetchWaitsForNetworkIdle(t *testing.T) {
+	requireBrowser(t)
+	srv := newSPAServer(t)
+	r := newRenderer(t)
+
+	page, err := r.Fetch(context.Background(), srv.URL+"/octocat", Options{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.HTML, "octocat") {
+		t.Fatalf("rendered HTML does not contain the JavaScript content:\n%s", page.HTML)
+	}
+}
+
+func TestFetchReportsStatus(t *testing.T) {
+	requireBrowser(t)
+	srv := newSPAServer(t)
+	r := newRenderer(t)
+
+	page, err := r.Fetch(context.Background(), srv.URL+"/missing", Options{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", page.StatusCode)
+	}
+}
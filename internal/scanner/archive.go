@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/archive"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+const warcSpec = "https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/"
+
+// archiveFinding writes a found page to the WARC file of username for --archive.
+func (s *Session) archiveFinding(username string, page *fetchedPage) *vars.ArchiveRecord {
+	if page.Exchange == nil {
+		return nil
+	}
+	w := s.archiveWriter(username)
+	if w == nil {
+		return nil
+	}
+	record, err := w.WriteExchange(*page.Exchange)
+	if err != nil {
+		s.print(printer.Error, "Could not archive %s: %v", page.URL, err)
+		return nil
+	}
+	return &vars.ArchiveRecord{
+		RecordID:      record.ID,
+		Date:          record.Date.Format(time.RFC3339),
+		URL:           page.URL,
+		PayloadSHA256: record.PayloadSHA256,
+		BlockSHA256:   record.BlockSHA256,
+	}
+}
+
+// archiveWriter returns the WARC file of username, creating it in the output folder on
+// first use. It is nil if the file could not be created.
+func (s *Session) archiveWriter(username string) *archive.Writer {
+	s.archiveMtx.Lock()
+	defer s.archiveMtx.Unlock()
+
+	if w, ok := s.archives[username]; ok {
+		return w
+	}
+	if s.archives == nil {
+		s.archives = make(map[string]*archive.Writer)
+	}
+
+	// Whether the reports go in a folder per username is only known at the end, so the
+	// file is written to the output folder and moved by output.SaveArchives
+	path := filepath.Join(vars.OutputFolder, "."+username+".warc.partial")
+	err := os.MkdirAll(vars.OutputFolder, 0755)
+	var w *archive.Writer
+	if err == nil {
+		w, err = archive.Create(path, map[string]string{
+			"software":   "Argus " + vars.Version,
+			"format":     "WARC File Format 1.1",
+			"conformsTo": warcSpec,
+			"username":   username,
+		})
+	}
+	if err != nil {
+		s.print(printer.Error, "Could not create the archive for %s, its pages won't be archived: %v", username, err)
+		s.archives[username] = nil
+		return nil
+	}
+
+	s.archives[username] = w
+	vars.ArchiveFiles[username] = &vars.ArchiveFile{Path: path}
+	return w
+}
+
+// closeArchives closes the WARC files once the scan is over.
+func (s *Session) closeArchives() {
+	s.archiveMtx.Lock()
+	defer s.archiveMtx.Unlock()
+
+	for username, w := range s.archives {
+		if w == nil {
+			continue
+		}
+		vars.ArchiveFiles[username].Records = w.Records()
+		if err := w.Close(); err != nil {
+			printer.Error("Could not write the archive for %s: %v", username, err)
+		}
+	}
+	s.archives = nil
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"context"
+	"sort"
+
+	"github.com/KillAllChickens/argus/internal/imagehash"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// avatarMatchBoost is added to the confidence of findings whose profile picture matches another finding's
+const avatarMatchBoost = 0.1
+
+// fetchAvatar downloads and hashes the profile picture of a finding for --save-images.
+// blocked is true when the picture is on the avatar blocklist, a default or placeholder
+// avatar that says nothing about the user.
+func (s *Session) fetchAvatar(ctx context.Context, URL string, pfpURL string) (avatar *vars.AvatarInfo, blocked bool) {
+	data, _, err := s.downloadImage(ctx, pfpURL)
+	if err != nil {
+		s.verbose(printer.Error, "Could not download profile picture for %s: %v", URL, err)
+		return nil, false
+	}
+	hashes, err := imagehash.Compute(data)
+	if err != nil {
+		s.verbose(printer.Error, "Could not hash profile picture for %s: %v", URL, err)
+		return nil, false
+	}
+
+	if entry, ok := blocklisted(hashes); ok {
+		s.verbose(printer.Info, "Dropping the profile picture of %s, it is a known placeholder (%s)", URL, entry)
+		return nil, true
+	}
+
+	return &vars.AvatarInfo{
+		URL:    pfpURL,
+		SHA256: hashes.SHA256,
+		DHash:  imagehash.FormatDHash(hashes.DHash),
+		Data:   data,
+		Format: hashes.Format,
+	}, false
+}
+
+// blocklisted returns the avatar_blocklist.txt entry matching the hashes: the same SHA-256,
+// or a dHash within imagehash.NearIdentical.
+func blocklisted(hashes imagehash.Hashes) (string, bool) {
+	for _, entry := range vars.AvatarBlocklist {
+		if len(entry) == 64 {
+			if entry == hashes.SHA256 {
+				return entry, true
+			}
+			continue
+		}
+		if dhash, err := imagehash.ParseDHash(entry); err == nil && imagehash.Distance(dhash, hashes.DHash) <= imagehash.NearIdentical {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+type avatarRef struct {
+	Username string
+	Site     string
+	DHash    uint64
+}
+
+// matchAvatars compares the saved profile pictures of every finding, across sites and
+// usernames, and records the near-identical ones as matches of each other.
+func matchAvatars() {
+	var refs []avatarRef
+	for _, username := range vars.Usernames {
+		sites := make([]string, 0, len(vars.FindingInfos[username]))
+		for site := range vars.FindingInfos[username] {
+			sites = append(sites, site)
+		}
+		sort.Strings(sites)
+		for _, site := range sites {
+			avatar := vars.FindingInfos[username][site].Avatar
+			if avatar == nil {
+				continue
+			}
+			if dhash, err := imagehash.ParseDHash(avatar.DHash); err == nil {
+				refs = append(refs, avatarRef{Username: username, Site: site, DHash: dhash})
+			}
+		}
+	}
+
+	for i := range refs {
+		for j := i + 1; j < len(refs); j++ {
+			a, b := refs[i], refs[j]
+			distance := imagehash.Distance(a.DHash, b.DHash)
+			if distance > imagehash.NearIdentical {
+				continue
+			}
+			addAvatarMatch(a, vars.AvatarMatch{Username: b.Username, Site: b.Site, Distance: distance})
+			addAvatarMatch(b, vars.AvatarMatch{Username: a.Username, Site: a.Site, Distance: distance})
+			printer.Success("Profile pictures match: %s on %s and %s on %s", a.Username, a.Site, b.Username, b.Site)
+		}
+	}
+}
+
+func addAvatarMatch(ref avatarRef, match vars.AvatarMatch) {
+	info := vars.FindingInfos[ref.Username][ref.Site]
+	if len(info.Avatar.Matches) == 0 {
+		info.Confidence = min(1, info.Confidence+avatarMatchBoost)
+	}
+	info.Avatar.Matches = append(info.Avatar.Matches, match)
+	vars.FindingInfos[ref.Username][ref.Site] = info
+}
@@ -0,0 +1,187 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/io"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// Retries of a blocked site, each through a new Tor circuit or another proxy
+const blockRetries = 2
+
+// Signs that a page is a block, captcha or bot challenge page instead of the site's own
+// answer, matched against the lowercased body
+var blockFingerprints = []string{
+	// Cloudflare
+	"cf-chl-",
+	"/cdn-cgi/challenge-platform/",
+	"cf-browser-verification",
+	"challenges.cloudflare.com/turnstile",
+	"<title>just a moment...</title>",
+	"<title>attention required! | cloudflare</title>",
+	// Captcha challenges
+	"captcha-delivery.com", // DataDome
+	"px-captcha",           // PerimeterX
+	// Generic block pages
+	"<title>access denied</title>",
+	"access denied</h1>",
+	"our systems have detected unusual traffic",
+	"unusual traffic from your computer network",
+	"request unsuccessful. incapsula incident id",
+	"please verify you are a human",
+	// To add more, use <CONFIG>/blockchecks.txt
+}
+
+// Captcha widgets, also found in the login, report or comment forms of real profiles. They
+// only mean a block on a page that looks like a challenge, see looksLikeChallenge.
+var captchaFingerprints = []string{
+	"class=\"g-recaptcha\"",
+	"www.google.com/recaptcha/api",
+	"class=\"h-captcha\"",
+	"hcaptcha.com/1/api.js",
+}
+
+// Challenge pages are small, profile pages rarely are
+const challengePageMaxSize = 16 << 10
+
+func initBlockChecks() {
+	checkfilepath, err := io.GetFilePath("blockchecks.txt")
+	if err != nil || checkfilepath == "" {
+		return
+	}
+	checks, _ := io.NewlineSeperatedFileToArray(checkfilepath)
+	for _, check := range checks {
+		blockFingerprints = append(blockFingerprints, strings.ToLower(check))
+	}
+}
+
+// detectBlock returns why page looks like a block page, or "" if it doesn't.
+func detectBlock(page *fetchedPage) string {
+	if page.StatusCode == http.StatusTooManyRequests {
+		return "rate limited"
+	}
+	if page.Header.Get("Cf-Mitigated") == "challenge" {
+		return "Cloudflare challenge"
+	}
+	// __cf_bm is set on every Cloudflare site, it only means a block with an error status
+	if page.StatusCode == http.StatusForbidden || page.StatusCode == http.StatusServiceUnavailable {
+		for _, cookie := range page.Header.Values("Set-Cookie") {
+			if strings.HasPrefix(cookie, "__cf_bm=") {
+				return fmt.Sprintf("Cloudflare bot protection (status %d)", page.StatusCode)
+			}
+		}
+	}
+
+	bodyLower := strings.ToLower(page.Body)
+	for _, fingerprint := range blockFingerprints {
+		if strings.Contains(bodyLower, fingerprint) {
+			return fmt.Sprintf("block page, matched %q", fingerprint)
+		}
+	}
+	if looksLikeChallenge(page) {
+		for _, fingerprint := range captchaFingerprints {
+			if strings.Contains(bodyLower, fingerprint) {
+				return fmt.Sprintf("captcha page, matched %q", fingerprint)
+			}
+		}
+	}
+	return ""
+}
+
+// looksLikeChallenge reports whether page has the error status or the small size of a
+// challenge page.
+func looksLikeChallenge(page *fetchedPage) bool {
+	switch page.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return len(page.Body) < challengePageMaxSize
+}
+
+// blockedError is returned when every attempt to fetch a page got a block page.
+type blockedError struct {
+	Reason   string
+	Attempts int
+}
+
+func (e *blockedError) Error() string {
+	return fmt.Sprintf("blocked after %d attempt(s): %s", e.Attempts, e.Reason)
+}
+
+// fetchUnblocked fetches reqURL like fetch, retrying through new Tor circuits or another
+// proxy while the answer is a block page, and returns a *blockedError if they all were.
+// The returned ctx has the proxy of the last attempt.
+func (s *Session) fetchUnblocked(ctx context.Context, site sites.Site, reqURL string) (context.Context, *fetchedPage, error) {
+	host := reqURL
+	if u, err := url.Parse(reqURL); err == nil {
+		host = u.Hostname()
+	}
+
+	for attempt := 1; ; attempt++ {
+		page, err := s.fetch(ctx, site, reqURL)
+		if err != nil {
+			return ctx, nil, err
+		}
+		reason := detectBlock(page)
+		if reason == "" {
+			return ctx, page, nil
+		}
+		if attempt > blockRetries {
+			return ctx, page, &blockedError{Reason: reason, Attempts: attempt}
+		}
+
+		retryCtx, how := s.rotateBlocked(ctx, reqURL, host)
+		if retryCtx == nil {
+			return ctx, page, &blockedError{Reason: reason, Attempts: attempt}
+		}
+		s.verbose(printer.Warning, "Blocked by %s (%s), retrying %s", host, reason, how)
+		ctx = s.withProfile(retryCtx, site)
+	}
+}
+
+// rotateBlocked gets a new exit for the retry of a blocked request: new Tor circuits, or
+// another proxy from the pool. It returns a nil context if there is nothing to rotate.
+func (s *Session) rotateBlocked(ctx context.Context, reqURL string, host string) (context.Context, string) {
+	if s.Proxies == nil {
+		return nil, ""
+	}
+	picked, _ := ctx.Value(pickedProxyKey{}).(*url.URL)
+	if s.isTor(picked) && s.rotateTor("after being blocked by "+host) {
+		retryCtx, err := s.withProxy(ctx, reqURL)
+		if err != nil {
+			return nil, ""
+		}
+		return retryCtx, "through new Tor circuits"
+	}
+	if s.Proxies.Len() < 2 {
+		return nil, ""
+	}
+	retryCtx, err := s.withOtherProxy(ctx, reqURL, picked)
+	if err != nil {
+		return nil, ""
+	}
+	return retryCtx, "through another proxy"
+}
+
+// recordBlocked keeps a site that stayed blocked, to list it in the reports.
+func (s *Session) recordBlocked(username string, URL string, blocked *blockedError) {
+	domain, err := GetMainDomain(URL)
+	if err != nil {
+		domain = URL
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.printLocked(printer.Warning, "BLOCKED: %s (%s)", URL, blocked.Reason)
+	if vars.BlockedSites[username] == nil {
+		vars.BlockedSites[username] = make(map[string]vars.BlockedSite)
+	}
+	vars.BlockedSites[username][domain] = vars.BlockedSite{URL: URL, Reason: blocked.Reason, Attempts: blocked.Attempts}
+}
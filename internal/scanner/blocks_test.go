@@ -0,0 +1,30 @@
+package scanner
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDetectBlockCaptcha(t *testing.T) {
+	widget := `<div class="g-recaptcha" data-sitekey="key"></div><script src="https://www.google.com/recaptcha/api.js"></script>`
+	profile := "<html><h1>alice</h1>" + strings.Repeat("<p>A post by alice</p>", 2000) + "<form>" + widget + "</form></html>"
+
+	tests := []struct {
+		name    string
+		page    fetchedPage
+		blocked bool
+	}{
+		{"profile with a captcha in a form", fetchedPage{StatusCode: http.StatusOK, Body: profile}, false},
+		{"small captcha page", fetchedPage{StatusCode: http.StatusOK, Body: "<html><p>Are you a robot?</p>" + widget + "</html>"}, true},
+		{"captcha with an error status", fetchedPage{StatusCode: http.StatusForbidden, Body: profile}, true},
+		{"DataDome challenge", fetchedPage{StatusCode: http.StatusOK, Body: profile + `<iframe src="https://geo.captcha-delivery.com/captcha/">`}, true},
+		{"plain profile", fetchedPage{StatusCode: http.StatusOK, Body: "<html><h1>alice</h1></html>"}, false},
+	}
+
+	for _, tt := range tests {
+		if reason := detectBlock(&tt.page); (reason != "") != tt.blocked {
+			t.Errorf("%s: detectBlock() = %q, want blocked %t", tt.name, reason, tt.blocked)
+		}
+	}
+}
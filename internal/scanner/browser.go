@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"context"
+
+	"github.com/KillAllChickens/argus/internal/browser"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// The browser profile every request for one site is made with
+type profileKey struct{}
+
+// setupBrowser pins one browser profile for the whole run, unless --browser is random.
+func (s *Session) setupBrowser() {
+	if vars.BrowserProfile == "" || vars.BrowserProfile == "random" {
+		return
+	}
+	profile, ok := browser.Random(vars.BrowserProfile)
+	if !ok {
+		return
+	}
+	s.browser = &profile
+	vars.BrowserProfile = profile.Name
+	printer.Info("Requesting every site as %s", profile.Name)
+}
+
+// pickProfile returns the browser profile to request site as: the one of its browser
+// option, the pinned one or a random one. Rendered sites are requested as Chrome, the
+// browser that renders them.
+func (s *Session) pickProfile(site sites.Site) browser.Profile {
+	if name := site.Browser(); name != "" {
+		if profile, ok := browser.Random(name); ok {
+			return profile
+		}
+	}
+	if s.browser != nil && (!site.Render() || s.browser.Family == browser.Chrome) {
+		return *s.browser
+	}
+	name := "random"
+	if site.Render() {
+		name = string(browser.Chrome)
+	}
+	profile, _ := browser.Random(name)
+	return profile
+}
+
+// withProfile picks the browser profile every request for site goes out as.
+func (s *Session) withProfile(ctx context.Context, site sites.Site) context.Context {
+	return context.WithValue(ctx, profileKey{}, s.pickProfile(site))
+}
+
+// profileFor returns the profile of ctx, or a new pick for site.
+func (s *Session) profileFor(ctx context.Context, site sites.Site) browser.Profile {
+	if profile, ok := ctx.Value(profileKey{}).(browser.Profile); ok {
+		return profile
+	}
+	return s.pickProfile(site)
+}
@@ -0,0 +1,223 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/KillAllChickens/argus/internal/fixtures"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+)
+
+// Outcome is the result of looking up a username on one site.
+type Outcome string
+
+const (
+	OutcomeFound    Outcome = "found"
+	OutcomeNotFound Outcome = "not_found"
+	OutcomeBlocked  Outcome = "blocked"
+	OutcomeError    Outcome = "error"
+)
+
+// siteCheck is what checkSite learned about a username on a site.
+type siteCheck struct {
+	Outcome  Outcome
+	Reason   string // why the username was not found, or the error
+	URL      string // display URL
+	ReqURL   string
+	Page     *fetchedPage
+	Baseline *fetchedPage // page of a non-existent user, nil if it could not be fetched
+
+	ctx     context.Context // with the proxy and browser profile the page was fetched with
+	blocked *blockedError
+}
+
+// checkSite requests the profile of username on source and decides whether it exists:
+// by status, soft 404 fingerprints and a comparison against a non-existent user.
+func (s *Session) checkSite(ctx context.Context, username string, source sites.Site) siteCheck {
+	check := siteCheck{
+		URL:    strings.ReplaceAll(source.DisplayURL, "{U}", username),
+		ReqURL: strings.ReplaceAll(source.ScanURL, "{U}", username),
+		ctx:    ctx,
+	}
+	notFound := func(reason string) siteCheck {
+		s.verbose(printer.Error, "'%s' not found in %s (%s)", username, check.URL, reason)
+		check.Outcome, check.Reason = OutcomeNotFound, reason
+		return check
+	}
+
+	ctx, err := s.withProxy(ctx, check.ReqURL)
+	if err != nil {
+		s.verbose(printer.Error, "Skipping %s: %v", source.ScanURL, err)
+		check.Outcome, check.Reason = OutcomeError, err.Error()
+		return check
+	}
+
+	ctx = context.WithValue(ctx, usernameKey{}, username)
+	ctx = s.withProfile(ctx, source)
+
+	ctx, res, err := s.fetchUnblocked(ctx, source, check.ReqURL)
+	check.ctx, check.Page = ctx, res
+	var blocked *blockedError
+	if errors.As(err, &blocked) {
+		check.Outcome, check.Reason, check.blocked = OutcomeBlocked, blocked.Reason, blocked
+		return check
+	}
+	if err != nil {
+		s.verbose(printer.Error, "Network error for %s: %v", check.ReqURL, err)
+		check.Outcome, check.Reason = OutcomeError, err.Error()
+		return check
+	}
+
+	if res.IsError() {
+		switch res.StatusCode {
+		case http.StatusNotFound, http.StatusGone:
+			return notFound(fmt.Sprintf("Status: %d", res.StatusCode))
+		default:
+			s.verbose(printer.Error, "Received error status %d for '%s' at %s", res.StatusCode, username, check.ReqURL)
+			check.Outcome, check.Reason = OutcomeError, fmt.Sprintf("status %d", res.StatusCode)
+			return check
+		}
+	}
+	if !res.IsSuccess() {
+		check.Outcome, check.Reason = OutcomeNotFound, fmt.Sprintf("status %d", res.StatusCode)
+		return check
+	}
+
+	bodyLower := strings.ToLower(res.Body)
+	usernameLower := strings.ToLower(username)
+
+	if !strings.Contains(bodyLower, usernameLower) {
+		return notFound("Soft 404 detected, username not in body")
+	}
+
+	for _, fingerprint := range soft404Fingerprints {
+		fingerprint = strings.ReplaceAll(fingerprint, "{U}", usernameLower)
+		fingerprint = strings.ToLower(fingerprint)
+
+		if strings.Contains(bodyLower, fingerprint) {
+			return notFound("Soft 404")
+		}
+	}
+
+	// Last and final check, against a non-existent user. The page is kept as a
+	// baseline for picking the profile picture.
+	nonExistantUsername, err := generateUsername(30)
+	if s.fixedBaseline {
+		nonExistantUsername = source.MissingUsername()
+	}
+	if err == nil {
+		nonExistentUserURL := strings.ReplaceAll(source.ScanURL, "{U}", nonExistantUsername)
+		baseline, err := s.fetch(ctx, source, nonExistentUserURL)
+		if err == nil {
+			check.Baseline = baseline
+			if !baseline.IsError() {
+				testBody := strings.ReplaceAll(strings.ToLower(baseline.Body), strings.ToLower(nonExistantUsername), usernameLower)
+				if testBody == bodyLower {
+					return notFound("Same as non-existent user")
+				}
+			}
+		}
+	}
+
+	check.Outcome = OutcomeFound
+	return check
+}
+
+var initChecksOnce sync.Once
+
+// initChecks loads the soft 404, bad redirect and block checks of the config directory.
+func initChecks() {
+	initChecksOnce.Do(func() {
+		init404Checks()
+		initBadRedirects()
+		initBlockChecks()
+	})
+}
+
+// ProbeResult is the outcome of a single lookup made by a Prober.
+type ProbeResult struct {
+	Outcome    Outcome `json:"outcome"`
+	Reason     string  `json:"reason,omitempty"`
+	URL        string  `json:"url"`
+	StatusCode int     `json:"status_code,omitempty"`
+}
+
+// Prober looks up usernames on single sites with the same checks as a scan, without
+// recording findings. Init has to be called first.
+type Prober struct {
+	session   *Session
+	recorder  *fixtures.Recorder
+	recordDir string
+	replayer  *fixtures.Replayer
+}
+
+// ProberOptions are the optional settings of a Prober.
+type ProberOptions struct {
+	// Directory the exchanges of every lookup are recorded to, one fixture per site
+	Record string
+	// Directory of fixtures to answer lookups from instead of the network
+	Replay string
+}
+
+// NewProber starts a session with the browser profile, credentials and proxies of the
+// config. When replaying, no request leaves the machine.
+func NewProber(ctx context.Context, opts ProberOptions) (*Prober, error) {
+	initChecks()
+	session, err := NewSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := &Prober{session: session}
+	session.setupBrowser()
+
+	if opts.Replay != "" {
+		p.replayer = fixtures.NewReplayer(opts.Replay)
+		session.Client.SetTransport(p.replayer)
+		session.fixedBaseline = true
+		return p, nil
+	}
+
+	session.setupCredentials()
+	session.setupTor()
+	session.setupProxies(ctx)
+	if opts.Record != "" {
+		p.recorder, p.recordDir = fixtures.NewRecorder(session.Client.Transport()), opts.Record
+		session.Client.SetTransport(p.recorder)
+		session.fixedBaseline = true
+	}
+	return p, nil
+}
+
+// Probe looks up username on site.
+func (p *Prober) Probe(ctx context.Context, site sites.Site, username string) ProbeResult {
+	if (p.recorder != nil || p.replayer != nil) && site.Render() {
+		return ProbeResult{Outcome: OutcomeError, Reason: "render=true sites can't be recorded or replayed", URL: strings.ReplaceAll(site.DisplayURL, "{U}", username)}
+	}
+	ctx = fixtures.WithSite(ctx, site.ScanURL)
+	check := p.session.checkSite(ctx, username, site)
+	result := ProbeResult{Outcome: check.Outcome, Reason: check.Reason, URL: check.URL}
+	if check.Page != nil {
+		result.StatusCode = check.Page.StatusCode
+	}
+	return result
+}
+
+// Close ends the prober's session, and saves the recorded fixtures.
+func (p *Prober) Close() error {
+	p.session.closeTor()
+	p.session.Close()
+	if p.recorder == nil {
+		return nil
+	}
+	saved, err := p.recorder.Save(p.recordDir)
+	if err != nil {
+		return err
+	}
+	printer.Success("Recorded %d fixture(s) to %s", saved, p.recordDir)
+	return nil
+}
@@ -0,0 +1,65 @@
+package scanner
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/credentials"
+	"github.com/KillAllChickens/argus/internal/io"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// setupCredentials loads credentials.json from the config directory, if there is one.
+func (s *Session) setupCredentials() {
+	path, err := io.GetFilePath("credentials.json")
+	if err != nil || path == "" {
+		return
+	}
+	set, err := credentials.Load(path)
+	if err != nil {
+		printer.Error("Could not load credentials.json: %v", err)
+		os.Exit(1)
+	}
+	s.credentials = set
+	for _, profile := range set.Profiles {
+		vars.CredentialProfiles = append(vars.CredentialProfiles, profile.Name)
+		printer.Info("Logging in to %s with credentials %q (%d cookies, %d headers)", strings.Join(profile.Domains, ", "), profile.Name, profile.CookieCount(), len(profile.Headers))
+	}
+}
+
+// credentialsFor returns the credential profile for requests to rawURL, nil if none.
+func (s *Session) credentialsFor(rawURL string) *credentials.Profile {
+	if s.credentials == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return s.credentials.For(u)
+}
+
+// redirectCredentials swaps the credentials of a redirected request for the ones of its
+// new domain, so they never follow a redirect to another site.
+func (s *Session) redirectCredentials(req *http.Request, via []*http.Request) {
+	if s.credentials == nil {
+		return
+	}
+	from := s.credentials.For(via[len(via)-1].URL)
+	to := s.credentials.For(req.URL)
+	if from == to {
+		return
+	}
+	if from != nil {
+		for name := range from.Headers {
+			req.Header.Del(name)
+		}
+	}
+	req.Header.Del("Cookie")
+	if to != nil {
+		to.Apply(req.Header, req.URL)
+	}
+}
@@ -0,0 +1,65 @@
+package scanner
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KillAllChickens/argus/internal/credentials"
+)
+
+func TestRedirectCredentials(t *testing.T) {
+	dir := t.TempDir()
+	cookies := ".instagram.com\tTRUE\t/\tTRUE\t0\tsessionid\tabc123def456\n" +
+		".example.com\tTRUE\t/\tTRUE\t0\tother\tfed654cba321\n"
+	config := `{
+		"instagram": {"domains": ["instagram.com"], "cookies": "cookies.txt", "headers": {"X-IG-App-ID": "936619743392459"}},
+		"example": {"domains": ["example.com"], "cookies": "cookies.txt", "headers": {"Authorization": "Bearer example-token"}}
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "cookies.txt"), []byte(cookies), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "credentials.json")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	set, err := credentials.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Session{credentials: set}
+
+	// redirect follows a request to from, with its credentials, to to
+	redirect := func(from, to string) http.Header {
+		t.Helper()
+		first, err := http.NewRequest(http.MethodGet, from, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.For(first.URL).Apply(first.Header, first.URL)
+		next, err := http.NewRequest(http.MethodGet, to, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// net/http copies the headers of the previous request
+		next.Header = first.Header.Clone()
+		s.redirectCredentials(next, []*http.Request{first})
+		return next.Header
+	}
+
+	h := redirect("https://www.instagram.com/alice", "https://instagram.com/accounts/login/")
+	if h.Get("X-Ig-App-Id") == "" || h.Get("Cookie") != "sessionid=abc123def456" {
+		t.Errorf("same domain redirect dropped the credentials: %v", h)
+	}
+
+	h = redirect("https://www.instagram.com/alice", "https://evilinstagram.com/")
+	if h.Get("X-Ig-App-Id") != "" || h.Get("Cookie") != "" {
+		t.Errorf("credentials followed a redirect to another domain: %v", h)
+	}
+
+	h = redirect("https://www.instagram.com/alice", "https://www.example.com/")
+	if h.Get("X-Ig-App-Id") != "" || h.Get("Authorization") != "Bearer example-token" || h.Get("Cookie") != "other=fed654cba321" {
+		t.Errorf("redirect to another profile's domain = %v, want only its credentials", h)
+	}
+}
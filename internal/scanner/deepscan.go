@@ -0,0 +1,506 @@
+package scanner
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/helpers"
+	"github.com/KillAllChickens/argus/internal/vars"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// A deep scan action turns the current values of a target into new ones.
+// Returning no values stops the pipeline and the target is skipped.
+type deepScanActionFunc func(values []string, action vars.DeepScanAction) ([]string, error)
+
+var deepScanActions = map[string]deepScanActionFunc{
+	"ignore_contains": actionIgnoreContains,
+	"remove_text":     actionRemoveText,
+	"trim":            actionTrim,
+	"lowercase":       actionLowercase,
+	"regex":           actionRegex,
+	"split":           actionSplit,
+	"parse_int":       actionParseInt,
+	"parse_date":      actionParseDate,
+}
+
+// Layouts tried by parse_date when the action has no value
+var defaultDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02/01/2006",
+	"January 2006",
+	"Jan 2006",
+}
+
+// DeepScanTrace records what a single target did, for `argus deepscan test`.
+type DeepScanTrace struct {
+	Target vars.DeepScanTarget
+	Raw    []string   // values matched by the selector or json_path
+	Steps  [][]string // values after each action
+	Err    error
+	Mapped vars.DeepScanResult // what the target maps to on its own
+}
+
+func performDeepScan(body string, pageURL string, config vars.DeepScanDomain) vars.DeepScanResult {
+	result, _ := TraceDeepScan(body, pageURL, config)
+	return result
+}
+
+// TraceDeepScan runs a deep scan and also returns what each target matched and how
+// its actions changed the values. Fields the targets didn't fill are taken from the
+// generic extraction (meta tags, JSON-LD, ...) when possible, result.FieldSources
+// records which was used for each field.
+func TraceDeepScan(body string, pageURL string, config vars.DeepScanDomain) (vars.DeepScanResult, []DeepScanTrace) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return vars.DeepScanResult{}, nil
+	}
+
+	// The body is only decoded as JSON when something needs it
+	var bodyJSON any
+	var bodyJSONErr error
+	var bodyJSONOnce sync.Once
+	getBodyJSON := func() (any, error) {
+		bodyJSONOnce.Do(func() { bodyJSON, bodyJSONErr = decodeJSON(body) })
+		return bodyJSON, bodyJSONErr
+	}
+
+	result := vars.DeepScanResult{}
+	traces := make([]DeepScanTrace, 0, len(config.Targets))
+
+	for _, target := range config.Targets {
+		values, err := extractTarget(doc, getBodyJSON, target)
+		trace := DeepScanTrace{Target: target, Raw: slices.Clone(values), Err: err}
+		if err != nil {
+			helpers.V("Deep scan target %s: %v", target.Name, err)
+			traces = append(traces, trace)
+			continue
+		}
+
+		// Apply actions
+		values, err = applyActions(values, target.Actions, func(step []string) {
+			trace.Steps = append(trace.Steps, slices.Clone(step))
+		})
+		if err != nil {
+			helpers.V("Deep scan target %s: %v", target.Name, err)
+			trace.Err = err
+		} else if len(values) > 0 {
+			mapDeepScanValues(&result, pageURL, target, slices.Clone(values))
+			mapDeepScanValues(&trace.Mapped, pageURL, target, values)
+		}
+		traces = append(traces, trace)
+	}
+
+	sources := make(map[string]string)
+	for _, field := range setFields(result) {
+		sources[field] = vars.FieldSourceSiteRule
+	}
+	for _, field := range fillMissing(&result, genericProfile(doc, pageURL, getBodyJSON)) {
+		sources[field] = vars.FieldSourceGeneric
+	}
+	if len(sources) > 0 {
+		result.FieldSources = sources
+	}
+
+	return result, traces
+}
+
+// extractTarget returns the raw values of a target: the text (or attribute) of the first
+// element matching the selector, or of every element when target.All is set. With a
+// json_path, the path is looked up in the JSON held by the selected elements (such as a
+// <script> tag) or, without a selector, in the body itself.
+func extractTarget(doc *goquery.Document, bodyJSON func() (any, error), target vars.DeepScanTarget) ([]string, error) {
+	if target.JSONPath == "" {
+		return selectValues(doc, target, target.All), nil
+	}
+
+	path, err := parseJSONPath(target.JSONPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []any
+	if target.Selector != "" {
+		for _, text := range selectValues(doc, target, true) {
+			if data, err := decodeJSON(text); err == nil {
+				documents = append(documents, data)
+			}
+		}
+	} else {
+		data, err := bodyJSON()
+		if err != nil {
+			return nil, fmt.Errorf("the page is not JSON: %w", err)
+		}
+		documents = append(documents, data)
+	}
+
+	var values []string
+	for _, data := range documents {
+		values = append(values, dropEmpty(jsonStrings(path.Eval(data)))...)
+	}
+	if !target.All && len(values) > 1 {
+		values = values[:1]
+	}
+	return values, nil
+}
+
+// selectValues returns the text (or target.Attr) of the first element matching the
+// target selector, or of every element if all is set.
+func selectValues(doc *goquery.Document, target vars.DeepScanTarget, all bool) []string {
+	selection := doc.Find(target.Selector)
+	if !all {
+		selection = selection.First()
+	}
+
+	var values []string
+	selection.Each(func(_ int, s *goquery.Selection) {
+		var value string
+		if target.Attr != "" {
+			value, _ = s.Attr(target.Attr)
+		} else {
+			value = s.Text()
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	})
+	return values
+}
+
+// applyActions runs the action pipeline of a target, in order. onStep, if not nil, is
+// called with the values after each action.
+func applyActions(values []string, actions []vars.DeepScanAction, onStep func([]string)) ([]string, error) {
+	for _, action := range actions {
+		apply, ok := deepScanActions[action.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown action type %q", action.Type)
+		}
+		var err error
+		values, err = apply(values, action)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", action.Type, err)
+		}
+		values = dropEmpty(values)
+		if onStep != nil {
+			onStep(values)
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+	}
+	return values, nil
+}
+
+// mapDeepScanValues maps the extracted data to the DeepScanResult struct
+func mapDeepScanValues(result *vars.DeepScanResult, pageURL string, target vars.DeepScanTarget, values []string) {
+	text := values[0]
+	switch target.Name {
+	case "linked_socials":
+		if links := normalizeLinkedSocials(pageURL, values); len(links) > 0 {
+			result.LinkedSocials = &links
+		}
+	case "public_post_count":
+		if postCount, err := helpers.ParseShorthandInt(strings.ReplaceAll(text, ",", "")); err == nil {
+			result.PublicPostCount = &postCount
+		}
+	case "description":
+		result.Description = &text
+	case "follower_count":
+		if followerCount, err := helpers.ParseShorthandInt(strings.ReplaceAll(text, ",", "")); err == nil {
+			result.FollowerCount = &followerCount
+		}
+	case "following_count":
+		if followingCount, err := helpers.ParseShorthandInt(strings.ReplaceAll(text, ",", "")); err == nil {
+			result.FollowingCount = &followingCount
+		}
+	case "real_name":
+		result.RealName = &text
+	default:
+		caser := cases.Title(language.English)
+		actionName := strings.ReplaceAll(target.Name, "_", " ")
+		actionName = strings.TrimSpace(actionName)
+		actionName = caser.String(actionName)
+		action := vars.NonDefinedAction{Name: actionName, Value: text}
+		if target.All || len(values) > 1 {
+			action.Value = strings.Join(values, ", ")
+			action.Values = values
+		}
+		result.NonDefinedActions = append(result.NonDefinedActions, action)
+	}
+}
+
+// normalizeLinkedSocials resolves the links against the page, drops links back to the
+// page's own site and keeps one link per domain.
+func normalizeLinkedSocials(pageURL string, links []string) []string {
+	pageDomain, _ := GetMainDomain(pageURL)
+	seen := make(map[string]bool)
+	var out []string
+
+	for _, link := range links {
+		resolved, err := resolveURL(pageURL, link)
+		if err != nil {
+			continue
+		}
+		u, err := url.Parse(resolved)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			continue
+		}
+		domain, err := GetMainDomain(u.Hostname())
+		if err != nil || domain == pageDomain || seen[domain] {
+			continue
+		}
+		seen[domain] = true
+
+		normalized := "https://" + strings.TrimPrefix(strings.ToLower(u.Host), "www.") + strings.TrimRight(u.EscapedPath(), "/")
+		if u.RawQuery != "" {
+			normalized += "?" + u.RawQuery
+		}
+		out = append(out, normalized)
+	}
+	return out
+}
+
+func actionIgnoreContains(values []string, action vars.DeepScanAction) ([]string, error) {
+	var out []string
+	for _, v := range values {
+		if !strings.Contains(v, action.Value) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func actionRemoveText(values []string, action vars.DeepScanAction) ([]string, error) {
+	for i, v := range values {
+		values[i] = strings.TrimSpace(strings.ReplaceAll(v, action.Value, ""))
+	}
+	return values, nil
+}
+
+// trim removes surrounding whitespace, or the characters in value if it is set
+func actionTrim(values []string, action vars.DeepScanAction) ([]string, error) {
+	for i, v := range values {
+		if action.Value != "" {
+			values[i] = strings.Trim(v, action.Value)
+		} else {
+			values[i] = strings.TrimSpace(v)
+		}
+	}
+	return values, nil
+}
+
+func actionLowercase(values []string, _ vars.DeepScanAction) ([]string, error) {
+	for i, v := range values {
+		values[i] = strings.ToLower(v)
+	}
+	return values, nil
+}
+
+// regex replaces each value with a capture group of its first match, values that don't match are dropped
+func actionRegex(values []string, action vars.DeepScanAction) ([]string, error) {
+	re, err := regexp.Compile(action.Value)
+	if err != nil {
+		return nil, err
+	}
+	group := regexGroup(re, action)
+	if group < 0 || group > re.NumSubexp() {
+		return nil, fmt.Errorf("capture group %d does not exist, the pattern has %d", group, re.NumSubexp())
+	}
+
+	var out []string
+	for _, v := range values {
+		if match := re.FindStringSubmatch(v); match != nil {
+			out = append(out, match[group])
+		}
+	}
+	return out, nil
+}
+
+func regexGroup(re *regexp.Regexp, action vars.DeepScanAction) int {
+	if action.Group != nil {
+		return *action.Group
+	}
+	if re.NumSubexp() == 0 {
+		return 0
+	}
+	return 1
+}
+
+// split splits every value on value (default ",") into several values
+func actionSplit(values []string, action vars.DeepScanAction) ([]string, error) {
+	sep := action.Value
+	if sep == "" {
+		sep = ","
+	}
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, sep) {
+			out = append(out, strings.TrimSpace(part))
+		}
+	}
+	return out, nil
+}
+
+// parse_int turns "1,234" or "1.2K" into "1234", values that aren't numbers are dropped
+func actionParseInt(values []string, _ vars.DeepScanAction) ([]string, error) {
+	var out []string
+	for _, v := range values {
+		n, err := helpers.ParseShorthandInt(strings.ReplaceAll(v, ",", ""))
+		if err == nil {
+			out = append(out, strconv.Itoa(n))
+		}
+	}
+	return out, nil
+}
+
+// parse_date normalizes dates to YYYY-MM-DD. value holds Go time layouts separated by "|",
+// when empty a few common layouts are tried. Values that aren't dates are dropped.
+func actionParseDate(values []string, action vars.DeepScanAction) ([]string, error) {
+	layouts := defaultDateLayouts
+	if action.Value != "" {
+		layouts = strings.Split(action.Value, "|")
+	}
+
+	var out []string
+	for _, v := range values {
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				out = append(out, t.Format("2006-01-02"))
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func dropEmpty(values []string) []string {
+	out := values[:0]
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ValidateDeepScanConfig checks deepscan.json for mistakes that would make a target silently return nothing.
+func ValidateDeepScanConfig(config map[string]vars.DeepScanDomain) []error {
+	var errs []error
+	for _, domain := range sortedDomains(config) {
+		for i, target := range config[domain].Targets {
+			errs = append(errs, validateTarget(domain, i, target)...)
+		}
+	}
+	return errs
+}
+
+// skipInvalidTargets removes the targets ValidateDeepScanConfig finds problems with from
+// config, and returns the problems.
+func skipInvalidTargets(config map[string]vars.DeepScanDomain) []error {
+	var errs []error
+	for _, domain := range sortedDomains(config) {
+		rules := config[domain]
+		var kept []vars.DeepScanTarget
+		for i, target := range rules.Targets {
+			if targetErrs := validateTarget(domain, i, target); len(targetErrs) > 0 {
+				errs = append(errs, targetErrs...)
+				continue
+			}
+			kept = append(kept, target)
+		}
+		rules.Targets = kept
+		config[domain] = rules
+	}
+	return errs
+}
+
+func sortedDomains(config map[string]vars.DeepScanDomain) []string {
+	domains := make([]string, 0, len(config))
+	for domain := range config {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// validateTarget checks target i of domain.
+func validateTarget(domain string, i int, target vars.DeepScanTarget) []error {
+	var errs []error
+	where := fmt.Sprintf("%s target %d", domain, i+1)
+	if target.Name != "" {
+		where = fmt.Sprintf("%s target %q", domain, target.Name)
+	}
+
+	if target.Name == "" {
+		errs = append(errs, fmt.Errorf("%s: missing \"name\"", where))
+	}
+	if target.Selector == "" && target.JSONPath == "" {
+		errs = append(errs, fmt.Errorf("%s: missing \"selector\" or \"json_path\"", where))
+	}
+	if target.Selector != "" {
+		if err := validateSelector(target.Selector); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", where, err))
+		}
+	}
+	if target.JSONPath != "" {
+		if _, err := parseJSONPath(target.JSONPath); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", where, err))
+		}
+	}
+
+	for j, action := range target.Actions {
+		if err := validateAction(action); err != nil {
+			errs = append(errs, fmt.Errorf("%s action %d: %v", where, j+1, err))
+		}
+	}
+	return errs
+}
+
+func validateSelector(selector string) error {
+	if _, err := cascadia.Compile(selector); err != nil {
+		return fmt.Errorf("invalid selector: %v", err)
+	}
+	return nil
+}
+
+func validateAction(action vars.DeepScanAction) error {
+	if _, ok := deepScanActions[action.Type]; !ok {
+		known := make([]string, 0, len(deepScanActions))
+		for name := range deepScanActions {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return fmt.Errorf("unknown action type %q (known: %s)", action.Type, strings.Join(known, ", "))
+	}
+
+	switch action.Type {
+	case "regex":
+		re, err := regexp.Compile(action.Value)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %v", err)
+		}
+		if group := regexGroup(re, action); group < 0 || group > re.NumSubexp() {
+			return fmt.Errorf("capture group %d does not exist, the pattern has %d", group, re.NumSubexp())
+		}
+	case "ignore_contains", "remove_text":
+		if action.Value == "" {
+			return fmt.Errorf("%s needs a \"value\"", action.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/colors"
+	"github.com/KillAllChickens/argus/internal/io"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// TestDeepScan runs the deepscan.json rules of domain against a saved page (file) or a
+// fetched one (pageURL) and prints what every target matched, the values after each
+// action and the field it maps to. Init has to be called first.
+func TestDeepScan(ctx context.Context, domain string, file string, pageURL string) error {
+	if vars.DeepScanConfig == nil {
+		return fmt.Errorf("deepscan.json could not be loaded, run 'argus deepscan validate' to find the problem")
+	}
+
+	mainDomain, err := GetMainDomain(domain)
+	if err != nil {
+		return err
+	}
+	config, ok := (*vars.DeepScanConfig)[mainDomain]
+	if !ok {
+		printer.Warning("deepscan.json has no rules for %s, only data embedded in the page will be read", mainDomain)
+	}
+
+	var body string
+	switch {
+	case file != "" && pageURL != "":
+		return fmt.Errorf("use either --file or --url, not both")
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		body = string(data)
+		pageURL = "https://" + mainDomain + "/"
+	case pageURL != "":
+		page, err := fetchForTest(ctx, mainDomain, pageURL)
+		if err != nil {
+			return err
+		}
+		printer.Info("Fetched %s (status %d)", page.URL, page.StatusCode)
+		body, pageURL = page.Body, page.URL
+	default:
+		return fmt.Errorf("a --file or --url is required")
+	}
+
+	result, traces := TraceDeepScan(body, pageURL, config)
+
+	found := 0
+	for i, trace := range traces {
+		fmt.Println()
+		printer.Info("Target %d: %s%s%s (%s)", i+1, colors.FgCyan, trace.Target.Name, colors.Reset, describeTarget(trace.Target))
+		if trace.Err != nil && len(trace.Raw) == 0 {
+			printer.Error("  %v", trace.Err)
+			continue
+		}
+		if len(trace.Raw) == 0 {
+			printer.Warning("  Selector matched nothing")
+			continue
+		}
+		fmt.Printf("    %-16s %q\n", "matched:", trace.Raw)
+		for j, step := range trace.Steps {
+			fmt.Printf("    %-16s %q\n", trace.Target.Actions[j].Type+":", step)
+		}
+		if trace.Err != nil {
+			printer.Error("  %v", trace.Err)
+			continue
+		}
+		mapped, _ := json.Marshal(trace.Mapped)
+		if string(mapped) == "{}" {
+			printer.Warning("  No value left after the actions")
+			continue
+		}
+		found++
+		fmt.Printf("    %-16s %s\n", "result:", mapped)
+	}
+
+	fmt.Println()
+	all, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	printer.Success("%d/%d targets produced a value:", found, len(traces))
+	fmt.Println(string(all))
+	return nil
+}
+
+func describeTarget(target vars.DeepScanTarget) string {
+	var parts []string
+	if target.Selector != "" {
+		parts = append(parts, "selector: "+target.Selector)
+	}
+	if target.JSONPath != "" {
+		parts = append(parts, "json_path: "+target.JSONPath)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fetchForTest fetches pageURL the way a scan would, rendering it if the site has render=true.
+func fetchForTest(ctx context.Context, mainDomain string, pageURL string) (*fetchedPage, error) {
+	session, err := NewSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	site := sites.Site{ScanURL: pageURL, DisplayURL: pageURL}
+	if sources, err := io.GetSources(); err == nil {
+		for _, source := range sources {
+			if domain, err := GetMainDomain(source.ScanURL); err == nil && domain == mainDomain {
+				site = source
+				break
+			}
+		}
+	}
+	return session.fetch(ctx, site, pageURL)
+}
+
+// ValidateDeepScanFile checks a deepscan.json file and prints every problem with its position.
+// It returns an error if there were any.
+func ValidateDeepScanFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	errs := CheckDeepScanJSON(data)
+	if len(errs) == 0 {
+		printer.Success("%s is valid", path)
+		return nil
+	}
+	for _, err := range errs {
+		printer.Error("%s: %v", path, err)
+	}
+	return fmt.Errorf("%s has %d problem(s)", path, len(errs))
+}
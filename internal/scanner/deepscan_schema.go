@@ -0,0 +1,319 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// DeepScanSchemaError is a problem in deepscan.json, at a line and column.
+type DeepScanSchemaError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e DeepScanSchemaError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Keys allowed in a target and in an action, with the JSON type of their value
+var (
+	deepScanTargetKeys = map[string]string{
+		"name":      "string",
+		"selector":  "string",
+		"json_path": "string",
+		"attr":      "string",
+		"all":       "boolean",
+		"actions":   "array",
+	}
+	deepScanActionKeys = map[string]string{
+		"type":  "string",
+		"value": "string",
+		"group": "number",
+	}
+)
+
+// jsonNode is a parsed JSON value that remembers where it is in the file.
+type jsonNode struct {
+	Start, End int64 // byte offsets of the value
+	Kind       string
+	Number     json.Number
+	Keys       []string // object keys, in order
+	KeyAt      map[string]int64
+	Fields     map[string]*jsonNode
+	Items      []*jsonNode
+}
+
+// CheckDeepScanJSON validates the content of deepscan.json: the JSON syntax, the expected
+// structure and keys, and the same checks as ValidateDeepScanConfig. Errors are
+// DeepScanSchemaErrors sorted by position.
+func CheckDeepScanJSON(data []byte) []error {
+	c := &schemaChecker{data: data}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := c.parse(dec)
+	if err != nil {
+		return []error{c.syntaxError(dec, err)}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		c.errorAt(c.skipSpace(dec.InputOffset()), "unexpected content after the end of the configuration")
+	}
+
+	c.checkConfig(root)
+
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		a, b := c.errs[i].(DeepScanSchemaError), c.errs[j].(DeepScanSchemaError)
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.errs
+}
+
+type schemaChecker struct {
+	data []byte
+	errs []error
+}
+
+func (c *schemaChecker) errorAt(offset int64, format string, a ...any) {
+	line, column := lineColumn(c.data, offset)
+	c.errs = append(c.errs, DeepScanSchemaError{Line: line, Column: column, Msg: fmt.Sprintf(format, a...)})
+}
+
+func (c *schemaChecker) syntaxError(dec *json.Decoder, err error) error {
+	offset := dec.InputOffset()
+	var syntaxErr *json.SyntaxError
+	switch {
+	case err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) || (errors.As(err, &syntaxErr) && strings.HasPrefix(syntaxErr.Error(), "unexpected end")):
+		offset = int64(len(c.data))
+		err = errors.New("unexpected end of file")
+	case errors.As(err, &syntaxErr):
+		// Offset is just after the invalid character
+		offset = max(syntaxErr.Offset-1, 0)
+	}
+	line, column := lineColumn(c.data, offset)
+	return DeepScanSchemaError{Line: line, Column: column, Msg: err.Error()}
+}
+
+// parse reads the next value from dec, recording its position.
+func (c *schemaChecker) parse(dec *json.Decoder) (*jsonNode, error) {
+	node := &jsonNode{Start: c.skipSpace(dec.InputOffset())}
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			node.Kind = "object"
+			node.KeyAt = make(map[string]int64)
+			node.Fields = make(map[string]*jsonNode)
+			for dec.More() {
+				keyAt := c.skipSpace(dec.InputOffset())
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := keyTok.(string)
+				child, err := c.parse(dec)
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := node.Fields[key]; ok {
+					c.errorAt(keyAt, "duplicate key %q", key)
+				} else {
+					node.Keys = append(node.Keys, key)
+				}
+				node.KeyAt[key] = keyAt
+				node.Fields[key] = child
+			}
+		} else {
+			node.Kind = "array"
+			for dec.More() {
+				child, err := c.parse(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.Items = append(node.Items, child)
+			}
+		}
+		if _, err := dec.Token(); err != nil { // closing delimiter
+			return nil, err
+		}
+	case string:
+		node.Kind = "string"
+	case json.Number:
+		node.Kind = "number"
+		node.Number = t
+	case bool:
+		node.Kind = "boolean"
+	case nil:
+		node.Kind = "null"
+	}
+	node.End = dec.InputOffset()
+	return node, nil
+}
+
+// skipSpace moves offset past the whitespace and separators the decoder hasn't consumed yet.
+func (c *schemaChecker) skipSpace(offset int64) int64 {
+	for offset < int64(len(c.data)) && strings.IndexByte(" \t\r\n,:", c.data[offset]) != -1 {
+		offset++
+	}
+	return offset
+}
+
+func (c *schemaChecker) expect(node *jsonNode, kind string, what string) bool {
+	if node.Kind != kind {
+		c.errorAt(node.Start, "%s must be %s %s, not %s", what, article(kind), kind, node.Kind)
+		return false
+	}
+	return true
+}
+
+func (c *schemaChecker) checkConfig(root *jsonNode) {
+	if !c.expect(root, "object", "deepscan.json") {
+		return
+	}
+	for _, domain := range root.Keys {
+		node := root.Fields[domain]
+		if !c.expect(node, "object", fmt.Sprintf("%q", domain)) {
+			continue
+		}
+		for _, key := range node.Keys {
+			if key != "targets" {
+				c.errorAt(node.KeyAt[key], "unknown key %q in %q, expected \"targets\"", key, domain)
+			}
+		}
+		targets, ok := node.Fields["targets"]
+		if !ok {
+			c.errorAt(node.Start, "%q has no \"targets\"", domain)
+			continue
+		}
+		if !c.expect(targets, "array", fmt.Sprintf("%q targets", domain)) {
+			continue
+		}
+		for i, target := range targets.Items {
+			c.checkTarget(fmt.Sprintf("%s target %d", domain, i+1), target)
+		}
+	}
+}
+
+func (c *schemaChecker) checkTarget(where string, node *jsonNode) {
+	if !c.expect(node, "object", where) {
+		return
+	}
+	keysOK := c.checkKeys(where, node, deepScanTargetKeys)
+	if _, ok := node.Fields["name"]; !ok {
+		c.errorAt(node.Start, "%s: missing \"name\"", where)
+	}
+	if node.Fields["selector"] == nil && node.Fields["json_path"] == nil {
+		c.errorAt(node.Start, "%s: missing \"selector\" or \"json_path\"", where)
+	}
+	if !keysOK {
+		return
+	}
+
+	if name := c.stringValue(node.Fields["name"]); name != "" {
+		where = fmt.Sprintf("%q", name)
+	}
+	if selector := node.Fields["selector"]; selector != nil {
+		if err := validateSelector(c.stringValue(selector)); err != nil {
+			c.errorAt(selector.Start, "%s: %v", where, err)
+		}
+	}
+	if path := node.Fields["json_path"]; path != nil {
+		if _, err := parseJSONPath(c.stringValue(path)); err != nil {
+			c.errorAt(path.Start, "%s: %v", where, err)
+		}
+	}
+
+	actions, ok := node.Fields["actions"]
+	if !ok {
+		return
+	}
+	for i, actionNode := range actions.Items {
+		actionWhere := fmt.Sprintf("%s action %d", where, i+1)
+		if !c.expect(actionNode, "object", actionWhere) || !c.checkKeys(actionWhere, actionNode, deepScanActionKeys) {
+			continue
+		}
+		if group, ok := actionNode.Fields["group"]; ok {
+			if _, err := strconv.Atoi(group.Number.String()); err != nil {
+				c.errorAt(group.Start, "%s: \"group\" must be a whole number", actionWhere)
+				continue
+			}
+		}
+		var action vars.DeepScanAction
+		if err := json.Unmarshal(c.data[actionNode.Start:actionNode.End], &action); err != nil {
+			c.errorAt(actionNode.Start, "%s: %v", actionWhere, err)
+			continue
+		}
+		if err := validateAction(action); err != nil {
+			c.errorAt(actionNode.Start, "%s: %v", actionWhere, err)
+		}
+	}
+}
+
+// stringValue returns the value of a string node, or "" for nil.
+func (c *schemaChecker) stringValue(node *jsonNode) string {
+	var s string
+	if node != nil {
+		_ = json.Unmarshal(c.data[node.Start:node.End], &s)
+	}
+	return s
+}
+
+// checkKeys reports unknown keys and values of the wrong type, returning false if there were any.
+func (c *schemaChecker) checkKeys(where string, node *jsonNode, allowed map[string]string) bool {
+	ok := true
+	for _, key := range node.Keys {
+		kind, known := allowed[key]
+		if !known {
+			c.errorAt(node.KeyAt[key], "%s: unknown key %q (expected one of %s)", where, key, keyList(allowed))
+			ok = false
+			continue
+		}
+		if !c.expect(node.Fields[key], kind, fmt.Sprintf("%s: %q", where, key)) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+func keyList(keys map[string]string) string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, strconv.Quote(name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func article(kind string) string {
+	if strings.ContainsRune("aeiou", rune(kind[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+// lineColumn converts a byte offset into a 1-based line and column (in characters).
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCount(before[lineStart:]) + 1
+}
@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckDeepScanJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string // "line:column message substring", in order
+	}{
+		{
+			name:   "valid",
+			config: `{"github.com": {"targets": [{"name": "bio", "selector": ".bio", "actions": [{"type": "trim"}]}]}}`,
+		},
+		{
+			name:   "syntax error",
+			config: "{\n  \"github.com\": {\"targets\": [}\n}",
+			want:   []string{"2:30 invalid character"},
+		},
+		{
+			name:   "unexpected end of file",
+			config: "{\n  \"github.com\": {",
+			want:   []string{"2:18 unexpected end of file"},
+		},
+		{
+			name:   "invalid last character",
+			config: `{"github.com": x`,
+			want:   []string{"1:16 invalid character 'x'"},
+		},
+		{
+			name:   "content after the configuration",
+			config: "{}\n}",
+			want:   []string{"2:1 unexpected content after the end"},
+		},
+		{
+			name: "unknown keys and wrong types",
+			config: `{
+  "github.com": {
+    "targets": [
+      {"name": "bio", "selectr": ".bio"},
+      {"name": "followers", "selector": ".followers", "all": "yes"}
+    ]
+  }
+}`,
+			want: []string{
+				`4:7 github.com target 1: missing "selector" or "json_path"`,
+				`4:23 github.com target 1: unknown key "selectr"`,
+				`5:62 github.com target 2: "all" must be a boolean, not string`,
+			},
+		},
+		{
+			name: "invalid selector, json_path and actions",
+			config: `{
+  "github.com": {"targets": [
+    {"name": "bio", "selector": "div[", "actions": [{"type": "regex", "value": "(a)", "group": 2}]},
+    {"name": "name", "json_path": "user..name", "actions": [{"type": "shout"}]}
+  ]}
+}`,
+			want: []string{
+				`3:33 "bio": invalid selector`,
+				`3:53 "bio" action 1: capture group 2 does not exist`,
+				`4:35 "name": json_path "user..name": missing key after '.'`,
+				`4:61 "name" action 1: unknown action type "shout"`,
+			},
+		},
+		{
+			name:   "columns count characters, not bytes",
+			config: "{\"é.com\": {\"targets\": [], \"x\": 1}}",
+			want:   []string{`1:27 unknown key "x" in "é.com"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := CheckDeepScanJSON([]byte(tt.config))
+			if len(errs) != len(tt.want) {
+				t.Fatalf("CheckDeepScanJSON() = %v, want %d errors", errs, len(tt.want))
+			}
+			for i, err := range errs {
+				var schemaErr DeepScanSchemaError
+				if !errors.As(err, &schemaErr) {
+					t.Fatalf("error %d is %T, want a DeepScanSchemaError", i, err)
+				}
+				position, msg, _ := strings.Cut(tt.want[i], " ")
+				got := strings.Join([]string{strconv.Itoa(schemaErr.Line), strconv.Itoa(schemaErr.Column)}, ":")
+				if got != position || !strings.Contains(schemaErr.Msg, msg) {
+					t.Errorf("error %d = %s:%q, want %s:%q", i, got, schemaErr.Msg, position, msg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+func TestApplyActions(t *testing.T) {
+	one := 1
+	two := 2
+	tests := []struct {
+		name    string
+		values  []string
+		actions []vars.DeepScanAction
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "actions run in order",
+			values:  []string{"  Followers: 1,234  "},
+			actions: []vars.DeepScanAction{{Type: "trim"}, {Type: "remove_text", Value: "Followers:"}, {Type: "parse_int"}},
+			want:    []string{"1234"},
+		},
+		{
+			name:    "split then ignore_contains",
+			values:  []string{"Go, Rust, PHP"},
+			actions: []vars.DeepScanAction{{Type: "split"}, {Type: "ignore_contains", Value: "PHP"}, {Type: "lowercase"}},
+			want:    []string{"go", "rust"},
+		},
+		{
+			name:    "split on a custom separator",
+			values:  []string{"a | b |"},
+			actions: []vars.DeepScanAction{{Type: "split", Value: "|"}},
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "trim given characters",
+			values:  []string{"@@alice@"},
+			actions: []vars.DeepScanAction{{Type: "trim", Value: "@"}},
+			want:    []string{"alice"},
+		},
+		{
+			name:    "parse_int keeps shorthand numbers and drops the rest",
+			values:  []string{"1.2K", "n/a", "3M"},
+			actions: []vars.DeepScanAction{{Type: "parse_int"}},
+			want:    []string{"1200", "3000000"},
+		},
+		{
+			name:    "no values left stops the pipeline",
+			values:  []string{"private account"},
+			actions: []vars.DeepScanAction{{Type: "ignore_contains", Value: "private"}, {Type: "unknown"}},
+			want:    nil,
+		},
+		{
+			name:    "unknown action",
+			values:  []string{"x"},
+			actions: []vars.DeepScanAction{{Type: "uppercase"}},
+			wantErr: true,
+		},
+		{
+			name:    "regex without groups keeps the whole match",
+			values:  []string{"joined in 2019", "no year"},
+			actions: []vars.DeepScanAction{{Type: "regex", Value: `\d{4}`}},
+			want:    []string{"2019"},
+		},
+		{
+			name:    "regex defaults to the first group",
+			values:  []string{"@alice (Alice)"},
+			actions: []vars.DeepScanAction{{Type: "regex", Value: `@(\w+) \((\w+)\)`}},
+			want:    []string{"alice"},
+		},
+		{
+			name:    "regex with a group",
+			values:  []string{"@alice (Alice)"},
+			actions: []vars.DeepScanAction{{Type: "regex", Value: `@(\w+) \((\w+)\)`, Group: &two}},
+			want:    []string{"Alice"},
+		},
+		{
+			name:    "regex with a missing group",
+			values:  []string{"alice"},
+			actions: []vars.DeepScanAction{{Type: "regex", Value: `\w+`, Group: &one}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid regex",
+			values:  []string{"alice"},
+			actions: []vars.DeepScanAction{{Type: "regex", Value: `(`}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var steps int
+			got, err := applyActions(slices.Clone(tt.values), tt.actions, func([]string) { steps++ })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyActions() error = %v, want error %t", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("applyActions() = %q, want %q", got, tt.want)
+			}
+			if tt.want != nil && steps != len(tt.actions) {
+				t.Errorf("onStep called %d times, want %d", steps, len(tt.actions))
+			}
+		})
+	}
+}
+
+func TestActionParseDate(t *testing.T) {
+	tests := []struct {
+		value  string
+		layout string
+		want   []string
+	}{
+		{value: "2021-03-04T05:06:07Z", want: []string{"2021-03-04"}},
+		{value: "2021-03-04", want: []string{"2021-03-04"}},
+		{value: "March 4, 2021", want: []string{"2021-03-04"}},
+		{value: "Mar 4, 2021", want: []string{"2021-03-04"}},
+		{value: "4 March 2021", want: []string{"2021-03-04"}},
+		{value: "04/03/2021", want: []string{"2021-03-04"}},
+		{value: "March 2021", want: []string{"2021-03-01"}},
+		{value: "yesterday", want: nil},
+		{value: "03/04/2021", layout: "01/02/2006", want: []string{"2021-03-04"}},
+		{value: "2021.03.04", layout: "01/02/2006|2006.01.02", want: []string{"2021-03-04"}},
+		{value: "2021-03-04", layout: "01/02/2006", want: nil},
+	}
+
+	for _, tt := range tests {
+		got, err := actionParseDate([]string{tt.value}, vars.DeepScanAction{Type: "parse_date", Value: tt.layout})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parse_date(%q, %q) = %q, want %q", tt.value, tt.layout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package scanner
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/archive"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/render"
+	"github.com/KillAllChickens/argus/internal/sites"
+)
+
+// fetchedPage is a page as seen by the detection logic, however it was fetched.
+type fetchedPage struct {
+	StatusCode int
+	Header     http.Header // nil for rendered pages
+	Body       string
+	URL        string // final URL after redirects
+	// The exact request and response, for --archive
+	Exchange *archive.Exchange
+}
+
+func (p *fetchedPage) IsError() bool   { return p.StatusCode > 399 }
+func (p *fetchedPage) IsSuccess() bool { return p.StatusCode > 199 && p.StatusCode < 300 }
+
+// fetch requests reqURL for site, through the headless browser if the site has render=true.
+func (s *Session) fetch(ctx context.Context, site sites.Site, reqURL string) (*fetchedPage, error) {
+	if site.Render() {
+		return s.fetchRendered(ctx, site, reqURL)
+	}
+
+	req := s.Client.R().SetContext(ctx)
+	s.profileFor(ctx, site).Apply(req.Header)
+	for name, value := range site.Headers() {
+		if value == "" {
+			req.Header.Del(name)
+		} else {
+			req.Header.Set(name, value)
+		}
+	}
+	creds := s.credentialsFor(reqURL)
+	if creds != nil {
+		if u, err := url.Parse(reqURL); err == nil {
+			creds.Apply(req.Header, u)
+		}
+	}
+
+	start := time.Now()
+	res, err := req.Get(reqURL)
+	s.reportProxy(ctx, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	s.torRequestDone(ctx)
+
+	finalURL := reqURL
+	if res.RawResponse != nil && res.RawResponse.Request != nil {
+		finalURL = res.RawResponse.Request.URL.String()
+	}
+	page := &fetchedPage{StatusCode: res.StatusCode(), Header: res.Header(), Body: res.String(), URL: finalURL}
+	if raw := res.RawResponse; raw != nil && raw.Request != nil {
+		page.Exchange = &archive.Exchange{
+			RequestedURL:  reqURL,
+			URL:           finalURL,
+			Method:        raw.Request.Method,
+			RequestHeader: s.credentials.RedactHeader(raw.Request.Header),
+			Proto:         raw.Proto,
+			StatusCode:    raw.StatusCode,
+			Status:        raw.Status,
+			Header:        raw.Header.Clone(),
+			Body:          res.Bytes(),
+			Date:          res.ReceivedAt(),
+		}
+		if creds != nil {
+			// The site may refresh the session cookies
+			creds.SetCookies(raw.Request.URL, raw.Cookies())
+			page.Exchange.Header = s.credentials.RedactHeader(raw.Header)
+		}
+		// RedirectHistory starts at the final response
+		history := res.RedirectHistory()
+		for i := len(history) - 1; i > 0; i-- {
+			page.Exchange.Redirects = append(page.Exchange.Redirects, history[i].URL)
+		}
+	}
+	return page, nil
+}
+
+func (s *Session) fetchRendered(ctx context.Context, site sites.Site, reqURL string) (*fetchedPage, error) {
+	ctx, renderer, err := s.getRenderer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := render.Options{
+		WaitSelector: site.WaitFor(),
+		UserAgent:    s.profileFor(ctx, site).UserAgent,
+		Headers:      site.Headers(),
+	}
+	// Only the cookies: extra headers would be sent to every domain the page loads from
+	if creds := s.credentialsFor(reqURL); creds != nil {
+		if u, err := url.Parse(reqURL); err == nil {
+			opts.Cookies = creds.Cookies(u)
+		}
+	}
+	start := time.Now()
+	page, err := renderer.Fetch(ctx, reqURL, opts)
+	s.reportProxy(ctx, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	// The status is unknown if the browser never saw a document response (e.g. served from cache)
+	if page.StatusCode == 0 {
+		page.StatusCode = http.StatusOK
+	}
+	return &fetchedPage{
+		StatusCode: page.StatusCode,
+		Body:       page.HTML,
+		URL:        page.URL,
+		Exchange: &archive.Exchange{
+			RequestedURL: reqURL,
+			URL:          page.URL,
+			StatusCode:   page.StatusCode,
+			Body:         []byte(page.HTML),
+			Date:         time.Now(),
+			Rendered:     true,
+		},
+	}, nil
+}
+
+// getRenderer starts the headless browser the first time a render=true site is scanned,
+// through one proxy of the pool, and restarts it through another when that proxy gets
+// evicted. It returns the ctx reporting to that proxy.
+func (s *Session) getRenderer(ctx context.Context) (context.Context, *render.Renderer, error) {
+	s.rendererMtx.Lock()
+	defer s.rendererMtx.Unlock()
+
+	if s.renderer != nil && s.rendererProxy != nil && !s.Proxies.IsLive(s.rendererProxy.String()) {
+		s.verbose(printer.Warning, "Proxy %s of the headless browser was evicted, restarting it", s.rendererProxy)
+		s.retired = append(s.retired, s.renderer)
+		s.renderer, s.rendererProxy = nil, nil
+	}
+	if s.renderer == nil && s.rendererErr == nil {
+		proxy := ""
+		if s.Proxies != nil {
+			picked, err := s.Proxies.Pick()
+			if err != nil {
+				return ctx, nil, err
+			}
+			s.rendererProxy = picked
+			proxy = picked.String()
+		}
+		s.renderer, s.rendererErr = render.New(context.WithoutCancel(ctx), proxy)
+		if s.rendererErr != nil {
+			s.print(printer.Error, "%v, skipping sites with render=true.", s.rendererErr)
+		}
+	}
+	if s.rendererProxy != nil {
+		ctx = context.WithValue(ctx, pickedProxyKey{}, s.rendererProxy)
+	}
+	return ctx, s.renderer, s.rendererErr
+}
@@ -0,0 +1,377 @@
+package scanner
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/helpers"
+	"github.com/KillAllChickens/argus/internal/vars"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Keys that usually hold a profile field in JSON (__NEXT_DATA__ blobs and JSON APIs), most likely first
+var profileKeys = map[string][]string{
+	"real_name":           {"fullName", "full_name", "displayName", "display_name", "name"},
+	"description":         {"bio", "biography", "description", "about", "signature"},
+	"follower_count":      {"followerCount", "followersCount", "follower_count", "followers_count", "followers"},
+	"following_count":     {"followingCount", "following_count", "followsCount", "friendsCount", "following"},
+	"public_post_count":   {"postCount", "postsCount", "post_count", "posts_count", "mediaCount", "statusesCount", "videoCount"},
+	"profile_picture_url": {"avatarUrl", "avatar_url", "profileImageUrl", "profile_image_url", "profilePicUrl", "profile_pic_url", "avatar"},
+}
+
+// Separators between a name and the site name in titles, "Jane Doe | Site", "Jane Doe • Site"
+var titleSeparators = []string{" | ", " • ", " · ", " - ", " — ", " – ", " on "}
+
+// handleInTitle matches the "(@handle)" many sites put after the display name
+var handleInTitle = regexp.MustCompile(`\s*\(@[^)]*\)`)
+
+// genericProfile reads the standard fields from data any site can have, from the most to
+// the least structured: schema.org JSON-LD (<script type="application/ld+json">), Next.js
+// __NEXT_DATA__ or a JSON body, OpenGraph and Twitter card meta tags, and rel=me links.
+func genericProfile(doc *goquery.Document, pageURL string, bodyJSON func() (any, error)) vars.DeepScanResult {
+	var result vars.DeepScanResult
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		data, err := decodeJSON(s.Text())
+		if err != nil {
+			return
+		}
+		for _, person := range ldPersons(data) {
+			fillMissing(&result, ldPersonResult(pageURL, person))
+		}
+	})
+
+	var blobs []any
+	if data, err := decodeJSON(doc.Find("script#__NEXT_DATA__").First().Text()); err == nil {
+		blobs = append(blobs, data)
+	}
+	if data, err := bodyJSON(); err == nil {
+		blobs = append(blobs, data)
+	}
+	for _, data := range blobs {
+		if profile := findProfileObject(data, pageURL); profile != nil {
+			fillMissing(&result, profileObjectResult(pageURL, profile))
+		}
+	}
+
+	fillMissing(&result, metaTagsResult(doc, pageURL))
+
+	return result
+}
+
+// metaTagsResult reads the OpenGraph (og:*) and Twitter card (twitter:*) tags and rel=me links.
+func metaTagsResult(doc *goquery.Document, pageURL string) vars.DeepScanResult {
+	var result vars.DeepScanResult
+
+	if title := metaContent(doc, "og:title", "twitter:title"); title != "" {
+		// Pages without a profile title often just have the site name
+		siteName, _, _ := strings.Cut(mainDomainOf(pageURL), ".")
+		if name := nameFromTitle(title); name != "" && !strings.EqualFold(name, siteName) {
+			result.RealName = &name
+		}
+	}
+	if description := metaContent(doc, "og:description", "twitter:description"); description != "" {
+		result.Description = &description
+	}
+	if image := metaContent(doc, "og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if resolved, err := resolveURL(pageURL, image); err == nil {
+			result.ProfilePictureURL = &resolved
+		}
+	}
+
+	var links []string
+	doc.Find(`a[rel~="me"], link[rel~="me"]`).Each(func(_ int, s *goquery.Selection) {
+		if href, ok := s.Attr("href"); ok {
+			links = append(links, href)
+		}
+	})
+	// twitter:creator is the account behind the page, on a profile usually the user themselves
+	if creator := strings.TrimPrefix(metaContent(doc, "twitter:creator"), "@"); creator != "" && !strings.ContainsAny(creator, " /") {
+		links = append(links, "https://x.com/"+creator)
+	}
+	if normalized := normalizeLinkedSocials(pageURL, links); len(normalized) > 0 {
+		result.LinkedSocials = &normalized
+	}
+
+	return result
+}
+
+func mainDomainOf(pageURL string) string {
+	domain, _ := GetMainDomain(pageURL)
+	return domain
+}
+
+// metaContent returns the content of the first meta tag found with one of names, looked
+// up in both the property and name attributes.
+func metaContent(doc *goquery.Document, names ...string) string {
+	for _, name := range names {
+		for _, attr := range []string{"property", "name"} {
+			content, ok := doc.Find(`meta[` + attr + `="` + name + `"]`).First().Attr("content")
+			if content = strings.TrimSpace(content); ok && content != "" {
+				return content
+			}
+		}
+	}
+	return ""
+}
+
+// nameFromTitle strips the site name and handle from a page title, "Jane Doe (@jane) | Site" becomes "Jane Doe".
+func nameFromTitle(title string) string {
+	for _, sep := range titleSeparators {
+		if before, _, ok := strings.Cut(title, sep); ok {
+			title = before
+		}
+	}
+	return strings.TrimSpace(handleInTitle.ReplaceAllString(title, ""))
+}
+
+// ldPersons returns every schema.org Person in a JSON-LD document, including the
+// mainEntity of a ProfilePage and the items of an @graph.
+func ldPersons(data any) []map[string]any {
+	var persons []map[string]any
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if hasLDType(v, "Person") {
+				persons = append(persons, v)
+				return
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(data)
+	return persons
+}
+
+func hasLDType(v map[string]any, ldType string) bool {
+	for _, t := range jsonStrings([]any{v["@type"]}) {
+		if t == ldType || strings.HasSuffix(t, "/"+ldType) {
+			return true
+		}
+	}
+	return false
+}
+
+func ldPersonResult(pageURL string, person map[string]any) vars.DeepScanResult {
+	var result vars.DeepScanResult
+	result.RealName = firstString(person["name"])
+	result.Description = firstString(person["description"])
+	if image, ok := person["image"].(map[string]any); ok {
+		result.ProfilePictureURL = firstString(image["url"])
+	} else {
+		result.ProfilePictureURL = firstString(person["image"])
+	}
+	if links := normalizeLinkedSocials(pageURL, jsonStrings([]any{person["sameAs"]})); len(links) > 0 {
+		result.LinkedSocials = &links
+	}
+
+	// interactionStatistic counts what others did (followers), agentInteractionStatistic
+	// what the person did (following, posts)
+	for _, stat := range ldStatistics(person["interactionStatistic"]) {
+		if ldInteraction(stat) == "FollowAction" {
+			result.FollowerCount = firstInt(stat["userInteractionCount"])
+		}
+	}
+	for _, stat := range ldStatistics(person["agentInteractionStatistic"]) {
+		switch ldInteraction(stat) {
+		case "FollowAction":
+			result.FollowingCount = firstInt(stat["userInteractionCount"])
+		case "WriteAction":
+			result.PublicPostCount = firstInt(stat["userInteractionCount"])
+		}
+	}
+	return result
+}
+
+func ldStatistics(v any) []map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		return []map[string]any{v}
+	case []any:
+		var stats []map[string]any
+		for _, item := range v {
+			if stat, ok := item.(map[string]any); ok {
+				stats = append(stats, stat)
+			}
+		}
+		return stats
+	}
+	return nil
+}
+
+// ldInteraction returns the action type of an InteractionCounter, e.g. "FollowAction"
+func ldInteraction(stat map[string]any) string {
+	interaction := stat["interactionType"]
+	if m, ok := interaction.(map[string]any); ok {
+		interaction = m["@type"]
+	}
+	for _, t := range jsonStrings([]any{interaction}) {
+		return t[strings.LastIndex(t, "/")+1:]
+	}
+	return ""
+}
+
+// findProfileObject returns the JSON object that looks the most like a user profile,
+// the one with the most keys from profileKeys, or nil if none has at least two. On a tie
+// (e.g. the viewer and the profile owner), an object holding the handle of pageURL wins,
+// then the first one in key order.
+func findProfileObject(data any, pageURL string) map[string]any {
+	handle := urlHandle(pageURL)
+	var best map[string]any
+	bestScore, bestOwner := 1, false
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if score := profileScore(v); score > 1 && score >= bestScore {
+				owner := handle != "" && holdsString(v, handle)
+				if score > bestScore || (owner && !bestOwner) {
+					best, bestScore, bestOwner = v, score, owner
+				}
+			}
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(v[key])
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(data)
+	return best
+}
+
+// urlHandle returns the last segment of the path of pageURL without a leading @, which is
+// the username on most profile URLs.
+func urlHandle(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	if len(segments) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(segments[len(segments)-1], "@")
+}
+
+// holdsString reports whether one of the string values of object is s, ignoring case.
+func holdsString(object map[string]any, s string) bool {
+	for _, v := range object {
+		if str, ok := v.(string); ok && strings.EqualFold(strings.TrimPrefix(str, "@"), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func profileScore(object map[string]any) int {
+	score := 0
+	for field := range profileKeys {
+		if profileValue(object, field) != nil {
+			score++
+		}
+	}
+	return score
+}
+
+// profileValue returns the value of the first key of field that holds a scalar
+func profileValue(object map[string]any, field string) any {
+	for _, key := range profileKeys[field] {
+		switch v := object[key].(type) {
+		case string:
+			if strings.TrimSpace(v) != "" {
+				return v
+			}
+		case json.Number:
+			return v
+		}
+	}
+	return nil
+}
+
+func profileObjectResult(pageURL string, profile map[string]any) vars.DeepScanResult {
+	var result vars.DeepScanResult
+	result.RealName = firstString(profileValue(profile, "real_name"))
+	result.Description = firstString(profileValue(profile, "description"))
+	result.FollowerCount = firstInt(profileValue(profile, "follower_count"))
+	result.FollowingCount = firstInt(profileValue(profile, "following_count"))
+	result.PublicPostCount = firstInt(profileValue(profile, "public_post_count"))
+	if avatar := firstString(profileValue(profile, "profile_picture_url")); avatar != nil {
+		if resolved, err := resolveURL(pageURL, *avatar); err == nil {
+			result.ProfilePictureURL = &resolved
+		}
+	}
+	return result
+}
+
+func firstString(v any) *string {
+	if values := jsonStrings([]any{v}); len(values) > 0 && values[0] != "" {
+		return &values[0]
+	}
+	return nil
+}
+
+func firstInt(v any) *int {
+	for _, value := range jsonStrings([]any{v}) {
+		if n, err := helpers.ParseShorthandInt(strings.ReplaceAll(value, ",", "")); err == nil {
+			return &n
+		}
+	}
+	return nil
+}
+
+// fillMissing copies the fields of src that are not set in dst yet, returning the json
+// names of the fields it set.
+func fillMissing(dst *vars.DeepScanResult, src vars.DeepScanResult) []string {
+	var filled []string
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for i := 0; i < d.NumField(); i++ {
+		if d.Field(i).Kind() == reflect.Ptr && d.Field(i).IsNil() && !s.Field(i).IsNil() {
+			d.Field(i).Set(s.Field(i))
+			filled = append(filled, jsonFieldName(d.Type().Field(i)))
+		}
+	}
+	return filled
+}
+
+// setFields returns the json names of the standard fields set in result.
+func setFields(result vars.DeepScanResult) []string {
+	var fields []string
+	v := reflect.ValueOf(result)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Kind() == reflect.Ptr && !v.Field(i).IsNil() {
+			fields = append(fields, jsonFieldName(v.Type().Field(i)))
+		}
+	}
+	return fields
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+// isEmptyDeepScan reports whether a deep scan found nothing at all.
+func isEmptyDeepScan(result vars.DeepScanResult) bool {
+	return reflect.ValueOf(result).IsZero()
+}
@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"testing"
+)
+
+// nextData is a __NEXT_DATA__ blob with the logged in viewer next to the profile owner,
+// both with as many profile keys.
+const nextData = `{"props": {"pageProps": {
+	"viewer":  {"username": "someone", "displayName": "Some One", "bio": "Viewing", "followerCount": 3},
+	"profile": {"username": "alice", "displayName": "Alice Liddell", "bio": "Down the rabbit hole", "followerCount": 42},
+	"author":  {"username": "bob", "displayName": "Bob", "bio": "Wrote a post", "followerCount": 7}
+}}}`
+
+func TestFindProfileObjectTie(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/alice":    "alice",
+		"https://example.com/@bob":     "bob",
+		"https://example.com/u/alice/": "alice",
+		// No object holds the handle, the first in key order wins
+		"https://example.com/carol": "bob",
+		"https://example.com/":      "bob",
+	}
+
+	for pageURL, want := range tests {
+		// Go randomizes map order, the same object has to win every time
+		for range 20 {
+			data, err := decodeJSON(nextData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			profile := findProfileObject(data, pageURL)
+			if got, _ := profile["username"].(string); got != want {
+				t.Fatalf("findProfileObject(%s) picked %q, want %q", pageURL, got, want)
+			}
+		}
+	}
+}
+
+func TestFindProfileObjectScore(t *testing.T) {
+	// The object with the most profile keys wins over one holding the handle
+	data, err := decodeJSON(`{"a": {"username": "alice", "name": "Alice", "bio": "short"},
+		"b": {"name": "Alice L.", "bio": "longer", "followers": 10, "avatar": "/a.png"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := findProfileObject(data, "https://example.com/alice"); got["name"] != "Alice L." {
+		t.Errorf("findProfileObject picked %v, want the object with the most profile keys", got)
+	}
+
+	data, err = decodeJSON(`{"user": {"username": "alice", "name": "Alice"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := findProfileObject(data, "https://example.com/alice"); got != nil {
+		t.Errorf("findProfileObject = %v, want nil for an object with a single profile key", got)
+	}
+}
@@ -0,0 +1,207 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// Health of a site, from looking up its known and missing usernames
+const (
+	HealthOK            = "ok"             // known user found, missing user not found
+	HealthFalsePositive = "false_positive" // missing user found
+	HealthFalseNegative = "false_negative" // known user not found
+	HealthBroken        = "broken"         // both wrong
+	HealthBlocked       = "blocked"
+	HealthError         = "error"
+	HealthUntested      = "untested" // no known= username
+)
+
+// SiteHealth is the result of checking one site.
+type SiteHealth struct {
+	Site    string       `json:"site"` // scan URL template
+	Domain  string       `json:"domain"`
+	Status  string       `json:"status"`
+	Known   *HealthProbe `json:"known,omitempty"`
+	Missing *HealthProbe `json:"missing,omitempty"`
+}
+
+// HealthProbe is the lookup of one test username.
+type HealthProbe struct {
+	Username string `json:"username"`
+	ProbeResult
+}
+
+// HealthReport is what argus sites check writes.
+type HealthReport struct {
+	Version   string         `json:"argus_version"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Duration  string         `json:"duration"`
+	Summary   map[string]int `json:"summary"`
+	Sites     []SiteHealth   `json:"sites"`
+}
+
+// CheckSites looks up the known and missing username of every enabled site (or of the
+// sites matching queries) with the scan's detection, and writes a health report to
+// outPath. It returns an error if a site is not healthy and strict is set. opts can
+// record the lookups as fixtures, or replay them.
+func CheckSites(ctx context.Context, queries []string, threads int, outPath string, strict bool, opts ProberOptions) error {
+	file, err := sourcesFile()
+	if err != nil {
+		return err
+	}
+	var targets []sites.Site
+	if len(queries) > 0 {
+		for _, query := range queries {
+			entry, err := file.Find(query)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, entry.Site)
+		}
+	} else {
+		for _, entry := range file.Entries() {
+			if !entry.Disabled {
+				targets = append(targets, entry.Site)
+			}
+		}
+	}
+
+	prober, err := NewProber(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	printer.Info("Checking %d site(s)", len(targets))
+	start := time.Now()
+	report := HealthReport{Version: vars.Version, CheckedAt: start.UTC(), Summary: make(map[string]int)}
+	report.Sites = make([]SiteHealth, len(targets))
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	jobs := make(chan int)
+	if threads < 1 {
+		threads = 1
+	}
+	for w := 0; w < threads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				health := checkSiteHealth(ctx, prober, targets[i])
+				mtx.Lock()
+				report.Sites[i] = health
+				printHealth(health)
+				mtx.Unlock()
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := prober.Close(); err != nil {
+		return err
+	}
+
+	for _, health := range report.Sites {
+		report.Summary[health.Status]++
+	}
+	report.Duration = time.Since(start).Round(time.Second).String()
+
+	if err := writeHealthReport(report, outPath); err != nil {
+		return err
+	}
+
+	statuses := make([]string, 0, len(report.Summary))
+	for status := range report.Summary {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		printer.Info("%-15s %d", status+":", report.Summary[status])
+	}
+	printer.Success("Health report written to %s", outPath)
+
+	// A site without known= could be broken without anyone noticing
+	if unhealthy := len(targets) - report.Summary[HealthOK]; strict && unhealthy > 0 {
+		return fmt.Errorf("%d site(s) are not healthy", unhealthy)
+	}
+	return nil
+}
+
+// checkSiteHealth looks up the test usernames of site.
+func checkSiteHealth(ctx context.Context, prober *Prober, site sites.Site) SiteHealth {
+	health := SiteHealth{Site: site.ScanURL, Domain: site.Host()}
+	if site.KnownUsername() == "" {
+		health.Status = HealthUntested
+		return health
+	}
+
+	health.Known = &HealthProbe{Username: site.KnownUsername(), ProbeResult: prober.Probe(ctx, site, site.KnownUsername())}
+	health.Missing = &HealthProbe{Username: site.MissingUsername(), ProbeResult: prober.Probe(ctx, site, site.MissingUsername())}
+	health.Status = healthStatus(health.Known.Outcome, health.Missing.Outcome)
+	return health
+}
+
+// healthStatus rates a site from the outcomes of its known and missing usernames.
+func healthStatus(known Outcome, missing Outcome) string {
+	switch {
+	case known == OutcomeBlocked || missing == OutcomeBlocked:
+		return HealthBlocked
+	case known == OutcomeError || missing == OutcomeError:
+		return HealthError
+	case known == OutcomeFound && missing == OutcomeFound:
+		return HealthFalsePositive
+	case known != OutcomeFound && missing == OutcomeFound:
+		return HealthBroken
+	case known != OutcomeFound:
+		return HealthFalseNegative
+	default:
+		return HealthOK
+	}
+}
+
+func printHealth(health SiteHealth) {
+	switch health.Status {
+	case HealthOK:
+		printer.Success("OK: %s", health.Site)
+	case HealthUntested:
+		printer.Warning("UNTESTED: %s (no known= username)", health.Site)
+	case HealthFalsePositive:
+		printer.Error("FALSE POSITIVE: %s ('%s' is found)", health.Site, health.Missing.Username)
+	case HealthFalseNegative:
+		printer.Error("FALSE NEGATIVE: %s ('%s' is not found: %s)", health.Site, health.Known.Username, health.Known.Reason)
+	case HealthBroken:
+		printer.Error("BROKEN: %s ('%s' is not found, '%s' is)", health.Site, health.Known.Username, health.Missing.Username)
+	case HealthBlocked:
+		printer.Warning("BLOCKED: %s", health.Site)
+	default:
+		reason := health.Known.Reason
+		if health.Known.Outcome != OutcomeError {
+			reason = health.Missing.Reason
+		}
+		printer.Error("ERROR: %s (%s)", health.Site, reason)
+	}
+}
+
+func writeHealthReport(report HealthReport, outPath string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, data, 0644)
+}
@@ -0,0 +1,27 @@
+package scanner
+
+import "testing"
+
+func TestHealthStatus(t *testing.T) {
+	tests := []struct {
+		known, missing Outcome
+		want           string
+	}{
+		{OutcomeFound, OutcomeNotFound, HealthOK},
+		{OutcomeFound, OutcomeFound, HealthFalsePositive},
+		{OutcomeNotFound, OutcomeNotFound, HealthFalseNegative},
+		{OutcomeNotFound, OutcomeFound, HealthBroken},
+		{OutcomeBlocked, OutcomeNotFound, HealthBlocked},
+		{OutcomeFound, OutcomeBlocked, HealthBlocked},
+		{OutcomeBlocked, OutcomeError, HealthBlocked},
+		{OutcomeError, OutcomeNotFound, HealthError},
+		{OutcomeFound, OutcomeError, HealthError},
+		{OutcomeError, OutcomeFound, HealthError},
+	}
+
+	for _, tt := range tests {
+		if got := healthStatus(tt.known, tt.missing); got != tt.want {
+			t.Errorf("healthStatus(%s, %s) = %s, want %s", tt.known, tt.missing, got, tt.want)
+		}
+	}
+}
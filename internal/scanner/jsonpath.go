@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed json_path, a small subset of JSONPath:
+//
+//	user.name, $.data.users[0].bio, links[*].url, ["key.with.dots"].value, items.*.id
+type jsonPath []jsonPathStep
+
+type jsonPathStep struct {
+	Key      string
+	Index    int
+	IsIndex  bool
+	Wildcard bool // [*] or *, every element of an array or value of an object
+}
+
+func parseJSONPath(path string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	if rest == "" {
+		return nil, fmt.Errorf("empty json_path")
+	}
+
+	var steps jsonPath
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, fmt.Errorf("json_path %q: missing key after '.'", path)
+			}
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("json_path %q: unterminated '['", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{Wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonPathStep{Key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("json_path %q: %q is not an index, a quoted key or *", path, inner)
+				}
+				steps = append(steps, jsonPathStep{Index: index, IsIndex: true})
+			}
+			continue
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end == -1 {
+			end = len(rest)
+		}
+		key := rest[:end]
+		rest = rest[end:]
+		if key == "*" {
+			steps = append(steps, jsonPathStep{Wildcard: true})
+		} else if key != "" {
+			steps = append(steps, jsonPathStep{Key: key})
+		}
+	}
+	return steps, nil
+}
+
+// Eval returns every value the path points to in root.
+func (p jsonPath) Eval(root any) []any {
+	current := []any{root}
+	for _, step := range p {
+		var next []any
+		for _, v := range current {
+			switch v := v.(type) {
+			case map[string]any:
+				if step.Wildcard {
+					keys := make([]string, 0, len(v))
+					for key := range v {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, v[key])
+					}
+				} else if child, ok := v[step.Key]; ok && !step.IsIndex {
+					next = append(next, child)
+				}
+			case []any:
+				switch {
+				case step.Wildcard:
+					next = append(next, v...)
+				case step.IsIndex:
+					index := step.Index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as json.Number.
+func decodeJSON(text string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// jsonStrings turns JSON values into deep scan values: scalars as text, arrays flattened
+// and objects as compact JSON. null is dropped.
+func jsonStrings(values []any) []string {
+	var out []string
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			out = append(out, strings.TrimSpace(v))
+		case json.Number:
+			out = append(out, v.String())
+		case bool:
+			out = append(out, strconv.FormatBool(v))
+		case []any:
+			out = append(out, jsonStrings(v)...)
+		default:
+			var b bytes.Buffer
+			enc := json.NewEncoder(&b)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(v); err == nil {
+				out = append(out, strings.TrimSpace(b.String()))
+			}
+		}
+	}
+	return out
+}
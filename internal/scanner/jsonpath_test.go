@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    jsonPath
+		wantErr bool
+	}{
+		{path: "user.name", want: jsonPath{{Key: "user"}, {Key: "name"}}},
+		{path: "$.data.users[0].bio", want: jsonPath{{Key: "data"}, {Key: "users"}, {Index: 0, IsIndex: true}, {Key: "bio"}}},
+		{path: "links[*].url", want: jsonPath{{Key: "links"}, {Wildcard: true}, {Key: "url"}}},
+		{path: `["key.with.dots"].value`, want: jsonPath{{Key: "key.with.dots"}, {Key: "value"}}},
+		{path: "['single'][-1]", want: jsonPath{{Key: "single"}, {Index: -1, IsIndex: true}}},
+		{path: "items.*.id", want: jsonPath{{Key: "items"}, {Wildcard: true}, {Key: "id"}}},
+		{path: "", wantErr: true},
+		{path: "$", wantErr: true},
+		{path: "user..name", wantErr: true},
+		{path: "user.", wantErr: true},
+		{path: "users[0", wantErr: true},
+		{path: "users[first]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJSONPath(%q) error = %v, want error %t", tt.path, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseJSONPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestJSONPathEval(t *testing.T) {
+	data, err := decodeJSON(`{
+		"user": {"name": "Alice", "followers": 42, "verified": true, "avatar": null},
+		"links": [{"url": "https://a.example"}, {"title": "no url"}, {"url": "https://b.example"}],
+		"tags": ["go", ["nested"]],
+		"stats": {"b": 2, "a": 1},
+		"key.with.dots": {"value": "dotted"}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "user.name", want: []string{"Alice"}},
+		{path: "$.user.followers", want: []string{"42"}},
+		{path: "user.verified", want: []string{"true"}},
+		{path: "user.avatar", want: nil},
+		{path: "links[*].url", want: []string{"https://a.example", "https://b.example"}},
+		{path: "links[-1].url", want: []string{"https://b.example"}},
+		{path: "links[3].url", want: nil},
+		{path: "tags", want: []string{"go", "nested"}},
+		{path: "stats.*", want: []string{"1", "2"}},
+		{path: "stats", want: []string{`{"a":1,"b":2}`}},
+		{path: `["key.with.dots"].value`, want: []string{"dotted"}},
+		{path: "user[0]", want: nil},
+		{path: "links.url", want: nil},
+		{path: "missing.key", want: nil},
+	}
+
+	for _, tt := range tests {
+		path, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := jsonStrings(path.Eval(data)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// pivotMaxWarned makes sure the --pivot-max warning is only printed once
+var pivotMaxWarned bool
+
+// bioLinkRegex finds links in free text, with or without a scheme ("x.com/someone")
+var bioLinkRegex = regexp.MustCompile(`(?i)(?:https?://)?(?:[a-z0-9-]+\.)+[a-z]{2,}/[^\s"'<>()\[\]]+`)
+
+// linkedAccount is an account linked from a found profile.
+type linkedAccount struct {
+	Username string
+	URL      string // the linked profile
+	FoundOn  string // domain of the profile that linked it
+}
+
+// linkedAccounts returns the accounts linked from username's deep scan results, in
+// linked_socials or in the description, found by matching the links against the site
+// URLs in sources.txt.
+func linkedAccounts(username string, sources []sites.Site) []linkedAccount {
+	domains := make([]string, 0, len(vars.DeepScanResults[username]))
+	for domain := range vars.DeepScanResults[username] {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	var accounts []linkedAccount
+	for _, domain := range domains {
+		result := vars.DeepScanResults[username][domain]
+
+		var links []string
+		if result.LinkedSocials != nil {
+			links = append(links, *result.LinkedSocials...)
+		}
+		if result.Description != nil {
+			links = append(links, bioLinks(*result.Description)...)
+		}
+
+		for _, link := range links {
+			for _, source := range sources {
+				if handle, ok := source.MatchUsername(link); ok {
+					accounts = append(accounts, linkedAccount{Username: handle, URL: link, FoundOn: domain})
+					break
+				}
+			}
+		}
+	}
+	return accounts
+}
+
+// bioLinks returns the links mentioned in a bio.
+func bioLinks(text string) []string {
+	var links []string
+	for _, link := range bioLinkRegex.FindAllString(text, -1) {
+		links = append(links, strings.TrimRight(link, ".,;:!?"))
+	}
+	return links
+}
+
+// queuePivots appends the accounts linked from username to the queue, as long as username
+// is less than vars.PivotDepth hops away from a username we were given. Usernames already
+// in the queue are never added twice, which also stops loops between profiles linking
+// each other.
+func queuePivots(username string, queue []string, sources []sites.Site) []string {
+	provenance := vars.UsernameProvenance[username]
+	if len(provenance) >= vars.PivotDepth {
+		return queue
+	}
+
+	for _, account := range linkedAccounts(username, sources) {
+		if containsFold(queue, account.Username) {
+			continue
+		}
+		if vars.PivotMax > 0 && len(vars.UsernameProvenance) >= vars.PivotMax {
+			if !pivotMaxWarned {
+				printer.Warning("Reached --pivot-max (%d), not queueing more linked accounts", vars.PivotMax)
+				pivotMaxWarned = true
+			}
+			return queue
+		}
+
+		chain := make([]vars.PivotStep, len(provenance), len(provenance)+1)
+		copy(chain, provenance)
+		chain = append(chain, vars.PivotStep{From: username, Site: account.FoundOn, URL: account.URL})
+		vars.UsernameProvenance[account.Username] = chain
+
+		printer.Info("Queued linked account '%s' (linked from %s: %s)", account.Username, account.FoundOn, account.URL)
+		queue = append(queue, account.Username)
+	}
+	return queue
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// Smallest width and height accepted for a profile picture, smaller images are icons
+	minPFPDimension = 48
+	// How much of an image is downloaded to read its dimensions
+	pfpHeaderBytes = 64 << 10
+)
+
+type selectorStrategy struct {
+	selector  string
+	attribute string
+}
+
+// Generic places a profile picture can be, best first
+var pfpStrategies = []selectorStrategy{
+	{`meta[property="og:image"]`, "content"},
+	{`meta[property="og:image:secure_url"]`, "content"},
+	{`meta[name="twitter:image"]`, "content"},
+	{`meta[name="twitter:image:src"]`, "content"},
+	{`img[class*="avatar"]`, "src"},
+	{`img[class*="profile"]`, "src"},
+	{`img[id*="avatar"]`, "src"},
+	{`img[id*="profile"]`, "src"},
+	{`img[alt*="avatar"]`, "src"},
+	{`img[alt*="profile"]`, "src"},
+	{`article img[src]`, "src"},
+	{`header img[src]`, "src"},
+}
+
+// Site icons, never a profile picture
+const faviconSelector = `link[rel~="icon"], link[rel="apple-touch-icon"], link[rel="apple-touch-icon-precomposed"], link[rel="mask-icon"]`
+
+// selectPFP picks the profile picture of a found page. Candidates are the site's pfp=
+// selectors from sources.txt, then the generic strategies, and a candidate is rejected if
+// it is the site's favicon, if the non-existent user page (baseline) has it too, or if
+// it is too small to be a profile picture.
+func (s *Session) selectPFP(ctx context.Context, site sites.Site, body string, pageURL string, baseline *fetchedPage) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	rejected := make(map[string]string)
+	for _, icon := range attrValues(doc, faviconSelector, "href", pageURL) {
+		rejected[strings.ToLower(icon)] = "the site's favicon"
+	}
+	if baseline != nil {
+		if baselineDoc, err := goquery.NewDocumentFromReader(strings.NewReader(baseline.Body)); err == nil {
+			for _, image := range pfpCandidates(baselineDoc, baseline.URL, site) {
+				rejected[strings.ToLower(image)] = "also on the non-existent user page"
+			}
+		}
+	}
+
+	for _, candidate := range pfpCandidates(doc, pageURL, site) {
+		if reason, ok := rejected[strings.ToLower(candidate)]; ok {
+			s.verbose(printer.Info, "Skipping profile picture %s for %s: %s", candidate, pageURL, reason)
+			continue
+		}
+		if u, err := url.Parse(candidate); err == nil && strings.EqualFold(u.Path, "/favicon.ico") {
+			continue
+		}
+		if err := s.checkPFPSize(ctx, candidate); err != nil {
+			s.verbose(printer.Info, "Skipping profile picture %s for %s: %v", candidate, pageURL, err)
+			continue
+		}
+		return candidate
+	}
+	return ""
+}
+
+// pfpCandidates returns the possible profile pictures of a page, resolved and without
+// duplicates: the site's own selectors first, then pfpStrategies.
+func pfpCandidates(doc *goquery.Document, baseURL string, site sites.Site) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	add := func(images []string) {
+		for _, image := range images {
+			if !seen[image] && !strings.HasPrefix(image, "data:") {
+				seen[image] = true
+				candidates = append(candidates, image)
+			}
+		}
+	}
+
+	if selector, attr := site.PFPSelector(); selector != "" {
+		add(attrValues(doc, selector, attr, baseURL))
+	}
+	for _, strategy := range pfpStrategies {
+		if images := attrValues(doc, strategy.selector, strategy.attribute, baseURL); len(images) > 0 {
+			add(images[:1])
+		}
+	}
+	return candidates
+}
+
+// attrValues returns the attribute of every element matching selector, resolved against
+// baseURL. Without an attribute, it is guessed from the element: src for images,
+// content for meta tags and href for the rest.
+func attrValues(doc *goquery.Document, selector string, attr string, baseURL string) []string {
+	var values []string
+	doc.Find(selector).Each(func(_ int, s *goquery.Selection) {
+		name := attr
+		if name == "" {
+			switch goquery.NodeName(s) {
+			case "img", "source":
+				name = "src"
+			case "meta":
+				name = "content"
+			default:
+				name = "href"
+			}
+		}
+		value, ok := s.Attr(name)
+		if value = strings.TrimSpace(value); !ok || value == "" {
+			return
+		}
+		if resolved, err := resolveURL(baseURL, value); err == nil {
+			values = append(values, resolved)
+		}
+	})
+	return values
+}
+
+// checkPFPSize downloads the start of an image and checks that it is an image of at least
+// minPFPDimension pixels each way. Formats that can't be decoded (such as SVG) pass.
+func (s *Session) checkPFPSize(ctx context.Context, imageURL string) error {
+	res, err := s.Client.R().
+		SetContext(ctx).
+		SetHeader("Range", fmt.Sprintf("bytes=0-%d", pfpHeaderBytes-1)).
+		SetDoNotParseResponse(true).
+		Get(imageURL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return fmt.Errorf("status %d", res.StatusCode())
+	}
+
+	contentType := strings.ToLower(res.Header().Get("Content-Type"))
+	if strings.HasPrefix(contentType, "text/") {
+		return fmt.Errorf("not an image (%s)", contentType)
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(res.Body, pfpHeaderBytes))
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	if config.Width < minPFPDimension || config.Height < minPFPDimension {
+		return fmt.Errorf("too small (%dx%d)", config.Width, config.Height)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KillAllChickens/argus/internal/sites"
+	"resty.dev/v3"
+)
+
+func TestSelectPFPSkipsUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := 100
+		if r.URL.Path == "/small.png" {
+			size = 16
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, image.NewGray(image.Rect(0, 0, size, size)))
+	}))
+	defer server.Close()
+
+	// Nothing listens on a closed server's address
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	s := &Session{Client: resty.New()}
+	defer func() { _ = s.Client.Close() }()
+	body := `<html><body>
+		<img class="avatar" src="` + closed.URL + `/avatar.png">
+		<img class="avatar" src="` + server.URL + `/small.png">
+		<img class="avatar" src="` + server.URL + `/avatar.png">
+		</body></html>`
+	site, err := sites.Parse(server.URL + `/{U} pfp="img.avatar"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := s.selectPFP(context.Background(), site, body, server.URL+"/alice", nil), server.URL+"/avatar.png"; got != want {
+		t.Errorf("selectPFP() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/helpers"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/proxy"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+const proxyCheckConcurrency = 20
+
+// setupProxies health checks vars.Proxies and routes every request of the session
+// through the ones that work. It exits if none do.
+func (s *Session) setupProxies(ctx context.Context) {
+	if len(vars.Proxies) == 0 {
+		return
+	}
+	pool, err := proxy.NewPool(vars.Proxies, proxy.Strategy(vars.ProxyStrategy), vars.ProxyMaxFailures)
+	helpers.HandleErr(err)
+
+	check := proxy.CheckConfig{
+		URL:            vars.ProxyCheck.URL,
+		ExpectedStatus: vars.ProxyCheck.ExpectedStatus,
+		Timeout:        time.Duration(vars.ProxyCheck.TimeoutSeconds * float64(time.Second)),
+		LeakCheck:      vars.ProxyCheck.LeakCheck,
+		IPURL:          vars.ProxyCheck.IPURL,
+	}
+	if check.LeakCheck {
+		check.RealIP, err = proxy.DirectIP(ctx, check)
+		if err != nil {
+			printer.Error("Could not get your IP for the proxy leak check: %v", err)
+			os.Exit(1)
+		}
+		helpers.V("Your IP without a proxy is %s", check.RealIP)
+	}
+
+	printer.Info("Testing %d proxies", len(vars.Proxies))
+	failed := pool.HealthCheck(ctx, proxy.NewChecker(check), proxyCheckConcurrency)
+	for _, stats := range failed {
+		printer.Error("%s is an invalid proxy, removing from list and continuing: %s", stats.Proxy, describeProxyError(stats.LastError))
+	}
+	if pool.Len() == 0 {
+		if vars.UseTor {
+			printer.Info("Do you have Tor installed and set up?")
+		}
+		printer.Error("No working proxies.")
+		os.Exit(1)
+	}
+	if len(vars.Proxies) <= 10 {
+		for _, stats := range pool.Stats() {
+			switch {
+			case stats.Evicted:
+			case stats.ExitIP != "":
+				printer.Success("Proxy %s works! (%v, exit IP %s)", stats.Proxy, stats.Latency.Round(time.Millisecond), stats.ExitIP)
+			default:
+				printer.Success("Proxy %s works! (%v)", stats.Proxy, stats.Latency.Round(time.Millisecond))
+			}
+		}
+	}
+	printer.Info("Running with %d proxies (%s)", pool.Len(), vars.ProxyStrategy)
+
+	pool.OnEvict = func(stats proxy.Stats) {
+		s.print(printer.Warning, "Evicted proxy %s after %d failures in a row: %v", stats.Proxy, stats.ConsecutiveFailures, stats.LastError)
+		if pool.Len() == 0 {
+			s.print(printer.Error, "Every proxy has been evicted, the remaining sites can't be scanned.")
+		}
+	}
+
+	transport, err := s.Client.HTTPTransport()
+	helpers.HandleErr(err)
+	transport.Proxy = pool.ProxyFunc()
+	s.Proxies = pool
+}
+
+// The proxy picked from the pool, before Tor stream isolation
+type pickedProxyKey struct{}
+
+// withProxy picks the proxy every request for the site of siteURL goes through.
+func (s *Session) withProxy(ctx context.Context, siteURL string) (context.Context, error) {
+	if s.Proxies == nil {
+		return ctx, nil
+	}
+	picked, err := s.Proxies.Pick()
+	if err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, pickedProxyKey{}, picked)
+	if s.isTor(picked) && vars.Tor.IsolateSites {
+		site, err := GetMainDomain(siteURL)
+		if err != nil {
+			site = siteURL
+		}
+		return proxy.WithProxy(ctx, isolateTor(picked, site)), nil
+	}
+	return proxy.WithProxy(ctx, picked), nil
+}
+
+// withOtherProxy is withProxy, avoiding the given proxy if the pool has others.
+func (s *Session) withOtherProxy(ctx context.Context, siteURL string, avoid *url.URL) (context.Context, error) {
+	for range s.Proxies.Len() * 2 {
+		retryCtx, err := s.withProxy(ctx, siteURL)
+		if err != nil {
+			return ctx, err
+		}
+		if picked, _ := retryCtx.Value(pickedProxyKey{}).(*url.URL); avoid == nil || picked.String() != avoid.String() {
+			return retryCtx, nil
+		}
+	}
+	return s.withProxy(ctx, siteURL)
+}
+
+// reportProxy records the outcome of a request made through the proxy of ctx. Only
+// network errors count against the proxy.
+func (s *Session) reportProxy(ctx context.Context, latency time.Duration, err error) {
+	if s.Proxies == nil {
+		return
+	}
+	used, ok := ctx.Value(pickedProxyKey{}).(*url.URL)
+	if !ok || errors.Is(err, errBadRedirect) || ctx.Err() != nil {
+		return
+	}
+	s.Proxies.Report(used.String(), latency, err)
+}
+
+// printProxyStats shows how every proxy did, with --verbose.
+func (s *Session) printProxyStats() {
+	if s.Proxies == nil || !vars.Verbose {
+		return
+	}
+	printer.Info("Proxy stats:")
+	for _, stats := range s.Proxies.Stats() {
+		line := printer.Info
+		state := ""
+		if stats.Evicted {
+			line = printer.Warning
+			state = " (evicted)"
+		}
+		if stats.ExitIP != "" {
+			state = ", exit IP " + stats.ExitIP + state
+		}
+		line("  %s: %d ok, %d failed, %v average latency%s", stats.Proxy, stats.Successes, stats.Failures, stats.Latency.Round(time.Millisecond), state)
+	}
+}
+
+// describeProxyError explains why a proxy failed its health check.
+func describeProxyError(err error) string {
+	if err == nil {
+		return "unknown error"
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "proxyconnect"), strings.Contains(msg, "connection refused"), strings.Contains(msg, "timeout"):
+		return "failed to connect or timed out: " + msg
+	case strings.Contains(msg, "protocol error"):
+		return "protocol error, is it the correct type (http/socks5)? " + msg
+	case strings.Contains(msg, "status "+strconv.Itoa(http.StatusForbidden)), strings.Contains(msg, "status "+strconv.Itoa(http.StatusProxyAuthRequired)):
+		return "it might require authentication or is blocked: " + msg
+	}
+	return msg
+}
@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+const (
+	// The config shipped with Argus, for its sources.txt and checks
+	bundledConfig = "../../config"
+	// Fixtures of the bundled sites, recorded with
+	//   argus sites check --record internal/scanner/testdata/fixtures [site ...]
+	fixturesDir = "testdata/fixtures"
+)
+
+func useBundledConfig(t *testing.T) {
+	t.Helper()
+	previous := vars.ConfigDir
+	vars.ConfigDir = bundledConfig
+	t.Cleanup(func() { vars.ConfigDir = previous })
+}
+
+// TestBundledSites replays the fixture of every enabled site of the bundled sources.txt
+// and checks that its known username is found and its missing username is not. Every
+// enabled site needs a known= username and a fixture, except render=true sites.
+func TestBundledSites(t *testing.T) {
+	useBundledConfig(t)
+	file, err := sites.LoadFile(filepath.Join(bundledConfig, "sources.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober, err := NewProber(context.Background(), ProberOptions{Replay: fixturesDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = prober.Close() })
+
+	for _, entry := range file.Entries() {
+		site := entry.Site
+		if entry.Disabled {
+			continue
+		}
+		t.Run(site.Host(), func(t *testing.T) {
+			switch {
+			case site.Render():
+				t.Skip("render=true sites can't be replayed")
+			case site.KnownUsername() == "":
+				t.Fatalf("no known= username, add an account confirmed to exist on %s", site.Host())
+			case !prober.replayer.Has(site.ScanURL):
+				t.Fatalf("no fixture, record one with: argus sites check --record internal/scanner/%s %s", fixturesDir, site.ScanURL)
+			}
+			ctx := context.Background()
+			if got := prober.Probe(ctx, site, site.KnownUsername()); got.Outcome != OutcomeFound {
+				t.Errorf("known user %q: %s (%s), want found", site.KnownUsername(), got.Outcome, got.Reason)
+			}
+			if got := prober.Probe(ctx, site, site.MissingUsername()); got.Outcome != OutcomeNotFound {
+				t.Errorf("missing user %q: %s (%s), want not_found", site.MissingUsername(), got.Outcome, got.Reason)
+			}
+		})
+	}
+}
+
+// newProfileSite serves profiles of the users in profiles. /u/ answers 404 for the
+// others, /soft/ a "user not found" page with status 200.
+func newProfileSite(t *testing.T, profiles ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		section, username, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		for _, profile := range profiles {
+			if username == profile {
+				_, _ = fmt.Fprintf(w, "<html><h1>%s</h1><p>Joined 2019, 12 followers</p></html>", username)
+				return
+			}
+		}
+		if section == "soft" {
+			_, _ = io.WriteString(w, "<html><h1>User not found</h1></html>")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestProbeRecordReplay records lookups against a live server and checks that replaying
+// them offline gives the same outcomes.
+func TestProbeRecordReplay(t *testing.T) {
+	useBundledConfig(t)
+	srv := newProfileSite(t, "alice")
+	ctx := context.Background()
+
+	var targets []sites.Site
+	for _, section := range []string{"u", "soft"} {
+		site, err := sites.Parse(srv.URL + "/" + section + "/{U} known=alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, site)
+	}
+	want := map[string]Outcome{"alice": OutcomeFound, sites.DefaultMissingUsername: OutcomeNotFound}
+
+	dir := t.TempDir()
+	recorder, err := NewProber(ctx, ProberOptions{Record: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, site := range targets {
+		for username, outcome := range want {
+			if got := recorder.Probe(ctx, site, username); got.Outcome != outcome {
+				t.Errorf("live %s, %q: %s (%s), want %s", site.ScanURL, username, got.Outcome, got.Reason, outcome)
+			}
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	replayer, err := NewProber(ctx, ProberOptions{Replay: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = replayer.Close() })
+	for _, site := range targets {
+		for username, outcome := range want {
+			if got := replayer.Probe(ctx, site, username); got.Outcome != outcome {
+				t.Errorf("replayed %s, %q: %s (%s), want %s", site.ScanURL, username, got.Outcome, got.Reason, outcome)
+			}
+		}
+	}
+
+	// Nothing was recorded for this user, the lookup must fail rather than go online
+	if got := replayer.Probe(ctx, targets[0], "bob"); got.Outcome != OutcomeError {
+		t.Errorf("unrecorded user: %s (%s), want error", got.Outcome, got.Reason)
+	}
+}
@@ -1,10 +1,11 @@
 package scanner
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
-	mrand "math/rand"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,23 +15,18 @@ import (
 	"sync"
 	"time"
 
-	"github.com/KillAllChickens/argus/internal/ai"
 	"github.com/KillAllChickens/argus/internal/colors"
 	"github.com/KillAllChickens/argus/internal/helpers"
 	"github.com/KillAllChickens/argus/internal/io"
 	"github.com/KillAllChickens/argus/internal/output"
 	"github.com/KillAllChickens/argus/internal/printer"
-	"github.com/KillAllChickens/argus/internal/shared"
+	"github.com/KillAllChickens/argus/internal/sites"
 	"github.com/KillAllChickens/argus/internal/vars"
 	"github.com/dustin/go-humanize"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gen2brain/beeep"
 	"github.com/schollz/progressbar/v3"
 	"golang.org/x/net/publicsuffix"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
-	"resty.dev/v3"
 )
 
 var soft404Fingerprints = []string{
@@ -69,55 +65,30 @@ var soft404Fingerprints = []string{
 
 var badRedirects []string // will be set based on <CONFIG>/BadRedirects.txt
 
-func StartScan(usernames []string) {
+func StartScan(ctx context.Context, usernames []string) {
+	vars.ScanStartedAt = time.Now()
 	printer.AsciiArtwork()
 	printer.Info("Starting Argus %s", vars.Version)
-	init404Checks()
-	initBadRedirects()
+	initChecks()
 	if vars.AI {
-		printer.Info("Running with Google Gemini capabilities")
+		printer.Info("Running with Google Gemini capabilities (%s)", vars.AIModel)
 	}
 
-	client := resty.New()
-	client.SetRedirectPolicy(resty.FlexibleRedirectPolicy(20))
-	client.SetTimeout(5 * time.Second)
-
-	if len(vars.Proxies) == 1 {
-		proxyTest := testProxy(vars.Proxies[0])
-		if proxyTest { // Proxy works as expected
-			client.SetProxy(vars.Proxies[0])
-		} else {
-			if vars.Proxies[0] == "socks5://127.0.0.1:9050" {
-				printer.Info("Do you have Tor installed and set up?")
-			}
-			os.Exit(1)
-		}
-	} else if len(vars.Proxies) > 1 {
-		if len(vars.Proxies) <= 10 {
-			printer.Info("Testing %d proxies", len(vars.Proxies))
-			for _, proxy := range vars.Proxies {
-				proxyTest := testProxy(proxy)
-				if proxyTest { // Proxy works as expected
-					printer.Success("Proxy %s works!", proxy)
-				} else {
-					printer.Error("%s is an invalid proxy, removing from list and continuing.", proxy)
-					remove(vars.Proxies, proxy)
-					continue
-				}
-			}
-		} else {
-			printer.Info("Running with %d proxies", len(vars.Proxies))
-		}
-		randomProxy := vars.Proxies[mrand.Intn(len(vars.Proxies))]
-		client.SetProxy(randomProxy)
-	}
+	session, err := NewSession(ctx)
+	helpers.HandleErr(err)
+	defer session.Close()
 
-	defer func() { _ = client.Close() }()
+	session.setupBrowser()
+	session.setupCredentials()
+	session.setupTor()
+	session.setupProxies(ctx)
 
 	sources, err := io.GetSources()
 	helpers.HandleErr(err)
+	sources = resolveSites(sources)
 
-	for i, username := range usernames {
+	for i := 0; i < len(usernames); i++ {
+		username := usernames[i]
 		scanDesc := fmt.Sprintf("%s[%d/%d]%s Searching '"+username+"'", colors.FgGreen, i+1, len(usernames), colors.Reset)
 		bar := progressbar.NewOptions(len(sources),
 			progressbar.OptionSetWriter(os.Stdout),
@@ -133,14 +104,11 @@ func StartScan(usernames []string) {
 				BarStart:      "[",
 				BarEnd:        "]",
 			}))
-		shared.Bar = bar
-		// bar.Describe(scanDesc)
-		// bar := progressbar.Default(100, scanDesc)
+		session.bar = bar
 
 		var wg sync.WaitGroup
-		var mtx sync.Mutex
 
-		jobs := make(chan string, len(sources))
+		jobs := make(chan sites.Site, len(sources))
 		var numWorkers int
 		if vars.AI {
 			numWorkers = 10
@@ -149,9 +117,9 @@ func StartScan(usernames []string) {
 		}
 
 		for w := 1; w <= numWorkers; w++ {
-			go func(id int, jobs <-chan string, wg *sync.WaitGroup, u string) {
+			go func(id int, jobs <-chan sites.Site, wg *sync.WaitGroup, u string) {
 				for source := range jobs {
-					FetchSource(client, u, source, bar, &mtx)
+					session.FetchSource(ctx, u, source)
 					wg.Done()
 				}
 			}(w, jobs, &wg, username)
@@ -163,12 +131,19 @@ func StartScan(usernames []string) {
 		}
 		close(jobs)
 		wg.Wait()
+
+		usernames = queuePivots(username, usernames, sources)
+		vars.Usernames = usernames
+
 		if i+1 != len(usernames) {
 			printer.Info("Finished search on %s, starting %s", username, usernames[i+1])
 		} else {
 			printer.Info("Finished search on %s", username)
 		}
 	}
+	session.printProxyStats()
+	session.closeTor()
+	session.closeArchives()
 	CompleteScanning()
 }
 
@@ -192,237 +167,131 @@ func initBadRedirects() {
 func Init(CustomConfigPath string) {
 	io.InitPaths(CustomConfigPath)
 	vars.InitConfVars()
-}
-
-// func FetchSource(username string, source string, bar *progressbar.ProgressBar) {
-func FetchSource(client *resty.Client, username string, source string, bar *progressbar.ProgressBar, mtx *sync.Mutex) {
-	// client := resty.New()
 
-	// defer client.Close()
-
-	// client.SetRedirectPolicy(resty.FlexibleRedirectPolicy(5))
-	// defer bar.Add(1)
-	//
-	if len(vars.Proxies) > 1 {
-		randomProxy := vars.Proxies[mrand.Intn(len(vars.Proxies))]
-		client.SetProxy(randomProxy)
+	if vars.DeepScanConfig != nil {
+		if errs := skipInvalidTargets(*vars.DeepScanConfig); len(errs) > 0 {
+			printer.Warning("deepscan.json has %d problem(s), the affected targets are skipped:", len(errs))
+			for _, err := range errs {
+				printer.Warning("  %v", err)
+			}
+		}
 	}
+}
 
-	defer func() { _ = bar.Add(1) }()
+type usernameKey struct{}
 
-	parts := strings.Split(source, "|")
-	URL := parts[len(parts)-1]
-	URL = strings.ReplaceAll(URL, "{U}", username)
+var errBadRedirect = errors.New("bad redirect")
 
-	reqURL := parts[0]
-	reqURL = strings.ReplaceAll(reqURL, "{U}", username)
+// checkRedirect refuses redirects to any of the pages in BadRedirects.txt
+func (s *Session) checkRedirect(req *http.Request, via []*http.Request) error {
+	s.verbose(printer.Error, "Redirect: %s -> %s", via[len(via)-1].URL.String(), req.URL.String())
+	s.redirectCredentials(req, via)
 
-	client.SetRedirectPolicy(resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
-		if vars.Verbose {
-			mtx.Lock()
-			_ = bar.Clear()
-			printer.Error("Redirect: %s -> %s", via[len(via)-1].URL.String(), req.URL.String())
-			mtx.Unlock()
-		}
-		for _, badRedirect := range badRedirects {
-			// printer.Info("Checking redirect: %s against %s", strings.ToLower(strings.ReplaceAll(badRedirect, "{U}", strings.ToLower(username))), strings.ToLower(normalizeURL(req.URL.String())))
-			// if strings.ToLower(normalizeURL(req.URL.String())) == strings.ToLower(strings.ReplaceAll(badRedirect, "{U}", username)) {
-			if strings.EqualFold(normalizeURL(req.URL.String()), strings.ReplaceAll(badRedirect, "{U}", username)) {
-				if vars.Verbose {
-					mtx.Lock()
-					_ = bar.Clear()
-					printer.Error("Bad Redirect: tried going to %s from %s", badRedirect, req.URL.String())
-					mtx.Unlock()
-				}
-				return fmt.Errorf("bad redirect: tried going to %s from %s", badRedirect, req.URL.String())
-			}
+	username, _ := req.Context().Value(usernameKey{}).(string)
+	for _, badRedirect := range badRedirects {
+		if strings.EqualFold(normalizeURL(req.URL.String()), strings.ReplaceAll(badRedirect, "{U}", username)) {
+			s.verbose(printer.Error, "Bad Redirect: tried going to %s from %s", badRedirect, req.URL.String())
+			return fmt.Errorf("%w: tried going to %s from %s", errBadRedirect, badRedirect, req.URL.String())
 		}
-		return nil
-	}))
-
-	// client.OnBeforeRedirect(func (req *resty.Request, via []*http.Request) error {
-	// 	for _, badRedirect := range badRedirects {
-	// 	if strings.Contains(badRedirect, req.URL) {
+	}
+	if len(via) >= 20 {
+		return fmt.Errorf("stopped after 20 redirects")
+	}
+	return nil
+}
 
-	// 	}
-	// 	}
-	// })
+func (s *Session) FetchSource(ctx context.Context, username string, source sites.Site) {
+	defer func() { _ = s.bar.Add(1) }()
 
-	res, err := client.R().
-		SetHeader("User-Agent", io.GetRandomUserAgent()).
-		Get(reqURL)
-	// helpers.HandleErr(err)
-	if err != nil {
-		if vars.Verbose {
-			mtx.Lock()
-			_ = bar.Clear()
-			printer.Error("Network error for %s: %v", reqURL, err)
-			mtx.Unlock()
-		}
+	check := s.checkSite(ctx, username, source)
+	switch check.Outcome {
+	case OutcomeFound:
+	case OutcomeBlocked:
+		s.recordBlocked(username, check.URL, check.blocked)
+		return
+	default:
 		return
 	}
+	ctx = check.ctx
+	URL, reqURL := check.URL, check.ReqURL
+	res, baseline := check.Page, check.Baseline
+	body := res.Body
 
-	if res.IsError() {
-		switch res.StatusCode() {
-		case http.StatusNotFound, http.StatusGone:
-			if vars.Verbose {
-				mtx.Lock()
-				_ = bar.Clear()
-				printer.Error("'%s' not found in %s (Status: %d)", username, reqURL, res.StatusCode())
-				mtx.Unlock()
-			}
-		default:
-			if vars.Verbose {
-				mtx.Lock()
-				_ = bar.Clear()
-				printer.Error("Received error status %d for '%s' at %s", res.StatusCode(), username, reqURL)
-				mtx.Unlock()
-			}
-		}
+	info := vars.FindingInfo{Confidence: confidenceHeuristic}
+	if variant, ok := vars.UsernameVariants[username]; ok {
+		info.Variant = &variant
+	}
+	info.Provenance = vars.UsernameProvenance[username]
+	if creds := s.credentialsFor(reqURL); creds != nil {
+		info.Credentials = creds.Name
+	}
+	if s.AI != nil && !s.verifyWithAI(ctx, username, URL, body, &info) {
 		return
 	}
-	if res.IsSuccess() {
-		body := res.String()
-		bodyLower := strings.ToLower(body)
-		usernameLower := strings.ToLower(username)
-
-		if !strings.Contains(bodyLower, usernameLower) {
-			if vars.Verbose {
-				mtx.Lock()
-				_ = bar.Clear()
-				printer.Error("'%s' not found in %s (Soft 404 detected, username not in body)", username, URL)
-				mtx.Unlock()
-			}
-			return
-		}
-
-		for _, fingerprint := range soft404Fingerprints {
-			fingerprint = strings.ReplaceAll(fingerprint, "{U}", usernameLower)
-			fingerprint = strings.ToLower(fingerprint)
-
-			if strings.Contains(bodyLower, fingerprint) {
-				if vars.Verbose {
-					mtx.Lock()
-					_ = bar.Clear()
-					printer.Error("'%s' not found in %s (Soft 404)", username, URL)
-					mtx.Unlock()
-				}
-				return
-			}
-		}
-
-		// Last and final check, against a non-existent user
-		nonExistantUsername, err := generateUsername(30)
-		if err == nil {
-			nonExistentUserURL := strings.ReplaceAll(reqURL, "{U}", nonExistantUsername)
-			testBody := getBodyLower(client, nonExistentUserURL)
-			testBody = strings.ReplaceAll(testBody, nonExistentUserURL, usernameLower)
-			if testBody != "" && testBody == bodyLower {
-				if vars.Verbose {
-					mtx.Lock()
-					_ = bar.Clear()
-					printer.Error("'%s' not found in %s (Same as non-existent user)", username, URL)
-					mtx.Unlock()
-				}
-				return
-			}
-		}
-
-		prompt := strings.ReplaceAll(vars.PromptHTMLCheckFP, "{S}", URL)
-		prompt = strings.ReplaceAll(prompt, "{U}", username)
-		// mtx.Lock()
-		verdict := strings.ToLower(ai.AIResponse(prompt, res.String()))
-		// mtx.Unlock()
-		verdict = strings.TrimSpace(verdict)
-		if vars.Verbose && vars.AI {
-			mtx.Lock()
-			_ = bar.Clear()
-			helpers.V("AI says '%s' for %s", verdict, URL)
-			mtx.Unlock()
-		}
-		if verdict == "true" {
-			mtx.Lock()
-			_ = bar.Clear()
-			printer.Success("FOUND: %s", URL)
-			MainDomain, err := GetMainDomain(URL)
-			if vars.FoundSites[username] == nil {
-				vars.FoundSites[username] = make(map[string]string)
-			}
-			vars.FoundSites[username][MainDomain] = URL
-			PFPUrl := ExtractPFP(body, URL)
-			if PFPUrl != "" {
-				if err != nil {
-					mtx.Lock()
-					_ = bar.Clear()
-					printer.Error("Failed to get main domain for %s: %s", URL, err)
-					mtx.Unlock()
-					return
-				}
-				// printer.Success("Found PFP for %s: %s", MainDomain, PFPUrl)
-				if vars.FoundPFPs[username] == nil {
-					vars.FoundPFPs[username] = make(map[string]string)
-				}
-				vars.FoundPFPs[username][MainDomain] = PFPUrl
-			}
-
-			if vars.DeepScanEnabled {
-				if domainConfig, ok := (*vars.DeepScanConfig)[MainDomain]; ok {
-					deepScanResult := performDeepScan(res.String(), domainConfig)
-					if vars.DeepScanResults[username] == nil {
-						vars.DeepScanResults[username] = make(map[string]vars.DeepScanResult)
-					}
-					vars.DeepScanResults[username][MainDomain] = deepScanResult
-				}
-			}
-
-			mtx.Unlock()
 
+	PFPUrl := s.selectPFP(ctx, source, body, URL, baseline)
+	if vars.SaveImages && PFPUrl != "" {
+		avatar, blocked := s.fetchAvatar(ctx, URL, PFPUrl)
+		if blocked {
+			PFPUrl = ""
 		}
+		info.Avatar = avatar
+	}
+	if s.Vision != nil && PFPUrl != "" {
+		s.checkVision(ctx, username, URL, body, PFPUrl, &info)
+	}
+	if vars.Archive {
+		info.Archive = s.archiveFinding(username, res)
 	}
-}
 
-type selectorStrategy struct {
-	selector  string
-	attribute string
-}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
 
-func ExtractPFP(body string, baseURL string) string {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if info.Unverified {
+		s.printLocked(printer.Warning, "FOUND (unverified): %s", URL)
+	} else {
+		s.printLocked(printer.Success, "FOUND: %s", URL)
+	}
+	MainDomain, err := GetMainDomain(URL)
 	if err != nil {
-		return ""
+		s.printLocked(printer.Error, "Failed to get main domain for %s: %s", URL, err)
+		return
+	}
+	if vars.FoundSites[username] == nil {
+		vars.FoundSites[username] = make(map[string]string)
+	}
+	vars.FoundSites[username][MainDomain] = URL
+	if vars.FindingInfos[username] == nil {
+		vars.FindingInfos[username] = make(map[string]vars.FindingInfo)
+	}
+	vars.FindingInfos[username][MainDomain] = info
+	if PFPUrl != "" {
+		// printer.Success("Found PFP for %s: %s", MainDomain, PFPUrl)
+		if vars.FoundPFPs[username] == nil {
+			vars.FoundPFPs[username] = make(map[string]string)
+		}
+		vars.FoundPFPs[username][MainDomain] = PFPUrl
 	}
 
-	strategies := []selectorStrategy{
-		{`meta[property="og:image"]`, "content"},
-		{`meta[property="og:image:secure_url"]`, "content"},
-		{`meta[name="twitter:image"]`, "content"},
-		{`meta[name="twitter:image:src"]`, "content"},
-		{`img[class*="avatar"]`, "src"},
-		{`img[class*="profile"]`, "src"},
-		{`img[id*="avatar"]`, "src"},
-		{`img[id*="profile"]`, "src"},
-		{`img[alt*="avatar"]`, "src"},
-		{`img[alt*="profile"]`, "src"},
-		{`article img[src]`, "src"},
-		{`header img[src]`, "src"},
-		{`link[rel="apple-touch-icon"]`, "href"},
-		{`link[rel="icon"]`, "href"},
-		{`link[rel="shortcut icon"]`, "href"},
-	}
-
-	for _, s := range strategies {
-		selection := doc.Find(s.selector).First()
-		if selection.Length() > 0 {
-			imageURL, exists := selection.Attr(s.attribute)
-			if exists && imageURL != "" {
-				resolvedURL, err := resolveURL(baseURL, imageURL)
-				if err == nil {
-					return resolvedURL
-				}
-			}
+	// The generic extraction (meta tags, JSON-LD, ...) runs on every found page, the
+	// deepscan.json rules only with --deep
+	var domainConfig vars.DeepScanDomain
+	if vars.DeepScanEnabled && vars.DeepScanConfig != nil {
+		domainConfig = (*vars.DeepScanConfig)[MainDomain]
+	}
+	deepScanResult := performDeepScan(body, URL, domainConfig)
+	if !isEmptyDeepScan(deepScanResult) {
+		if vars.DeepScanResults[username] == nil {
+			vars.DeepScanResults[username] = make(map[string]vars.DeepScanResult)
 		}
+		vars.DeepScanResults[username][MainDomain] = deepScanResult
 	}
-	return ""
+}
+
+// replacePlaceholders fills in {U} (username) and {S} (site URL) in prompts.
+func replacePlaceholders(text string, username string, URL string) string {
+	text = strings.ReplaceAll(text, "{S}", URL)
+	return strings.ReplaceAll(text, "{U}", username)
 }
 
 func resolveURL(base, image string) (string, error) {
@@ -463,6 +332,10 @@ func GetMainDomain(rawURL string) (string, error) {
 }
 
 func CompleteScanning() {
+	if vars.SaveImages {
+		matchAvatars()
+	}
+
 	for _, username := range vars.Usernames {
 		// printer.Success("Found %d sites for %s!", len(vars.FoundSites[username]), username)
 		if len(vars.FoundSites[username]) == 0 {
@@ -470,7 +343,11 @@ func CompleteScanning() {
 		}
 		printer.Info("Found %d sites for %s:", len(vars.FoundSites[username]), username)
 		for n, site := range vars.FoundSites[username] {
-			printer.Success("%-14s => %-45s", n, site)
+			if vars.FindingInfos[username][n].Unverified {
+				printer.Warning("%-14s => %-45s (unverified)", n, site)
+			} else {
+				printer.Success("%-14s => %-45s", n, site)
+			}
 			if deepScanData, ok := vars.DeepScanResults[username][n]; ok {
 				if deepScanData.Description != nil {
 					printer.Info("  Description: %s", *deepScanData.Description)
@@ -492,7 +369,16 @@ func CompleteScanning() {
 			}
 		}
 	}
-	if len(vars.OutputTypes) == 0 {
+	for _, username := range vars.Usernames {
+		if len(vars.BlockedSites[username]) == 0 {
+			continue
+		}
+		printer.Warning("%d sites blocked the scan of %s, they could not be checked:", len(vars.BlockedSites[username]), username)
+		for n, blocked := range vars.BlockedSites[username] {
+			printer.Warning("%-14s => %-45s (%s)", n, blocked.URL, blocked.Reason)
+		}
+	}
+	if len(vars.OutputTypes) == 0 && !vars.SaveImages && !vars.Archive {
 		printer.Success("Scanning complete!")
 		compExit()
 	}
@@ -507,6 +393,12 @@ func CompleteScanning() {
 			}
 		}
 	}
+	if vars.SaveImages {
+		output.SaveImages()
+	}
+	if vars.Archive {
+		output.SaveArchives()
+	}
 	if len(vars.OutputTypes) != 0 {
 		printer.Info("Outputting results to %s", vars.OutputFolder)
 		for _, outputType := range vars.OutputTypes {
@@ -524,23 +416,11 @@ func CompleteScanning() {
 			}
 		}
 	}
+	output.WriteManifest()
 	printer.Success("Scanning complete!")
 	compExit()
 }
 
-func getBodyLower(client *resty.Client, url string) string {
-
-	// URL = strings.ReplaceAll(URL, "{U}", username)
-
-	res, err := client.R().
-		SetHeader("User-Agent", io.GetRandomUserAgent()).
-		Get(url)
-	if err != nil || res.IsError() {
-		return ""
-	}
-	return strings.ToLower(res.String())
-}
-
 func generateUsername(length int) (string, error) {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	result := make([]byte, length)
@@ -591,101 +471,6 @@ func normalizeURL(fullURL string) string {
 	return normalizedFull
 }
 
-func testProxy(proxyAddr string) bool {
-	client := resty.New()
-	client.SetProxy(proxyAddr)
-	client.SetTimeout(10 * time.Second)
-
-	client.SetRedirectPolicy(resty.NoRedirectPolicy())
-	resp, err := client.R().Get("http://ipinfo.io/ip")
-
-	if err != nil {
-		if strings.Contains(err.Error(), "proxyconnect") ||
-			strings.Contains(err.Error(), "connection refused") ||
-			strings.Contains(err.Error(), "timeout") {
-			printer.Error("Proxy %s failed to connect or timed out: %v", proxyAddr, err)
-		} else if strings.Contains(err.Error(), "protocol error") {
-			printer.Error("Proxy %s had a protocol error. Is it the correct type (http/socks5)? %v", proxyAddr, err)
-		} else {
-			printer.Error("Failed to use proxy %s for request: %v", proxyAddr, err)
-		}
-		return false
-	}
-
-	if resp.StatusCode() != http.StatusOK {
-		if resp.StatusCode() == http.StatusForbidden || resp.StatusCode() == http.StatusProxyAuthRequired {
-			printer.Warning("Proxy %s might require authentication or is blocked.", proxyAddr)
-		}
-		return false
-	}
-
-	return true
-}
-
-func performDeepScan(body string, config vars.DeepScanDomain) vars.DeepScanResult {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
-	if err != nil {
-		return vars.DeepScanResult{}
-	}
-
-	result := vars.DeepScanResult{}
-
-	for _, target := range config.Targets {
-		selection := doc.Find(target.Selector)
-		helpers.V("target.Selector: %s", target.Selector)
-		text := strings.TrimSpace(selection.First().Text())
-
-		// Apply actions
-		for _, action := range target.Actions {
-			if action.Type == "ignore_contains" && strings.Contains(text, action.Value) {
-				text = ""
-				break
-			}
-			if action.Type == "remove_text" && strings.Contains(text, action.Value) {
-				text = strings.TrimSpace(strings.ReplaceAll(text, action.Value, ""))
-			}
-		}
-
-		if text == "" {
-			continue
-		}
-
-		// Map the extracted data to the DeepScanResult struct
-		switch target.Name {
-		case "description":
-			result.Description = &text
-		case "follower_count":
-			if followerCount, err := helpers.ParseShorthandInt(strings.ReplaceAll(text, ",", "")); err == nil {
-				result.FollowerCount = &followerCount
-			}
-		case "following_count":
-			if followingCount, err := helpers.ParseShorthandInt(strings.ReplaceAll(text, ",", "")); err == nil {
-				result.FollowingCount = &followingCount
-			}
-		case "real_name":
-			result.RealName = &text
-		default:
-			caser := cases.Title(language.English)
-			actionName := strings.ReplaceAll(target.Name, "_", " ")
-			actionName = strings.TrimSpace(actionName)
-			actionName = caser.String(actionName)
-			result.NonDefinedActions = append(result.NonDefinedActions, vars.NonDefinedAction{Name: actionName, Value: text})
-		}
-	}
-
-	return result
-}
-
-func remove[T comparable](l []T, item T) []T {
-	out := make([]T, 0)
-	for _, element := range l {
-		if element != item {
-			out = append(out, element)
-		}
-	}
-	return out
-}
-
 func humanizeIfInt(s string) string {
 	n, err := strconv.Atoi(s)
 	if err != nil {
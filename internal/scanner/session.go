@@ -0,0 +1,173 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/ai"
+	"github.com/KillAllChickens/argus/internal/archive"
+	"github.com/KillAllChickens/argus/internal/browser"
+	"github.com/KillAllChickens/argus/internal/credentials"
+	"github.com/KillAllChickens/argus/internal/helpers"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/proxy"
+	"github.com/KillAllChickens/argus/internal/render"
+	"github.com/KillAllChickens/argus/internal/vars"
+
+	"github.com/schollz/progressbar/v3"
+	"resty.dev/v3"
+)
+
+// Session owns the long-lived clients used for a scan run.
+type Session struct {
+	Client *resty.Client
+	AI     *ai.Client // nil when --ai is off
+	Vision *ai.Client // nil when --ai-vision is off, may be the same client as AI
+	// Proxies every request goes through, nil without --proxy, --proxy-list or --tor
+	Proxies *proxy.Pool
+	// Tor circuit rotation, nil without --tor
+	tor *torCircuits
+	// Browser profile of every request, nil to pick one per site
+	browser *browser.Profile
+	// Logins from credentials.json, nil without one
+	credentials *credentials.Set
+	// Compare pages against the site's missing username instead of a random one, so
+	// recorded fixtures replay (see Prober)
+	fixedBaseline bool
+
+	bar *progressbar.ProgressBar
+	mtx sync.Mutex // guards the result maps and console output
+
+	aiQuotaOnce sync.Once
+
+	// headless browser for render=true sites, started on first use. All its requests go
+	// through rendererProxy, it is restarted when that proxy is evicted.
+	renderer      *render.Renderer
+	rendererProxy *url.URL
+	rendererErr   error
+	retired       []*render.Renderer // replaced renderers, other workers may still use them
+	rendererMtx   sync.Mutex
+
+	// username -> WARC file, with --archive
+	archives   map[string]*archive.Writer
+	archiveMtx sync.Mutex
+}
+
+func NewSession(ctx context.Context) (*Session, error) {
+	s := &Session{}
+
+	s.Client = resty.New()
+	s.Client.SetRedirectPolicy(resty.RedirectPolicyFunc(s.checkRedirect))
+	s.Client.SetTimeout(5 * time.Second)
+
+	if vars.AI {
+		aiClient, err := ai.NewClient(ctx, vars.GeminiAPIKey, vars.AIProvider, vars.AIModel)
+		if err != nil {
+			_ = s.Client.Close()
+			return nil, err
+		}
+		aiClient.Limiter.OnWait = s.onAIWait
+		s.AI = aiClient
+	}
+
+	if vars.AI && vars.AIVision {
+		if vars.AIVisionModel == "" || vars.AIVisionModel == vars.AIModel {
+			s.Vision = s.AI
+		} else {
+			visionClient, err := ai.NewClient(ctx, vars.GeminiAPIKey, vars.AIProvider, vars.AIVisionModel)
+			if err != nil {
+				_ = s.Client.Close()
+				return nil, err
+			}
+			visionClient.Limiter.OnWait = s.onAIWait
+			s.Vision = visionClient
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Session) onAIWait(wait time.Duration, reason string) {
+	s.verbose(helpers.V, "AI rate limit reached (%s), sleeping for %v...", reason, wait.Round(time.Second))
+}
+
+func (s *Session) Close() {
+	if s.renderer != nil {
+		s.renderer.Close()
+	}
+	for _, renderer := range s.retired {
+		renderer.Close()
+	}
+	_ = s.Client.Close()
+}
+
+// print clears the progress bar and prints a line without interleaving with other workers.
+func (s *Session) print(print func(format string, a ...any), format string, a ...any) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.printLocked(print, format, a...)
+}
+
+// printLocked is print for callers that already hold s.mtx. Secrets from
+// credentials.json are redacted.
+func (s *Session) printLocked(print func(format string, a ...any), format string, a ...any) {
+	if s.bar != nil {
+		_ = s.bar.Clear()
+	}
+	if s.credentials != nil {
+		print("%s", s.credentials.Redact(fmt.Sprintf(format, a...)))
+		return
+	}
+	print(format, a...)
+}
+
+// verbose is print, but only with --verbose.
+func (s *Session) verbose(print func(format string, a ...any), format string, a ...any) {
+	if vars.Verbose {
+		s.print(print, format, a...)
+	}
+}
+
+// verifyWithAI asks the AI whether the page is a real profile. keep is false when the
+// finding should be dropped, either because the AI said so or because verification
+// failed under the fail-closed policy. On a fail-open failure, info is marked unverified.
+func (s *Session) verifyWithAI(ctx context.Context, username string, URL string, body string, info *vars.FindingInfo) (keep bool) {
+	prompt := replacePlaceholders(vars.PromptHTMLCheckFP, username, URL)
+
+	exists, err := s.AI.Verify(ctx, prompt, body)
+	if err != nil {
+		s.reportAIError(URL, err)
+		if vars.AIFailPolicy == vars.AIFailClosed {
+			s.verbose(printer.Error, "Dropping %s, AI verification failed (--ai-fail-policy closed)", URL)
+			return false
+		}
+		info.Unverified = true
+		info.UnverifiedReason = err.Error()
+		info.Confidence = confidenceUnverified
+		return true
+	}
+
+	if exists {
+		info.Confidence = confidenceAIVerified
+	}
+	s.verbose(helpers.V, "AI says '%t' for %s", exists, URL)
+	return exists
+}
+
+func (s *Session) reportAIError(URL string, err error) {
+	if errors.Is(err, ai.ErrDailyQuota) {
+		s.aiQuotaOnce.Do(func() {
+			if vars.AIFailPolicy == vars.AIFailClosed {
+				s.print(printer.Warning, "Hit AI quota limit for today, remaining findings will be dropped (--ai-fail-policy closed).")
+			} else {
+				s.print(printer.Warning, "Hit AI quota limit for today, remaining findings will be marked as unverified.")
+			}
+		})
+		return
+	}
+	s.verbose(printer.Error, "AI verification failed for %s: %v", URL, err)
+}
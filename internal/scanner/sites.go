@@ -0,0 +1,248 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/helpers"
+	"github.com/KillAllChickens/argus/internal/io"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+// sourcesFile loads the sources.txt of the config directory.
+func sourcesFile() (*sites.File, error) {
+	path, err := io.GetFilePath("sources.txt")
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("sources.txt not found in %s", vars.ConfigDir)
+	}
+	return sites.LoadFile(path)
+}
+
+// resolveSites applies the site filter of the scan to all, and shows the sites left.
+func resolveSites(all []sites.Site) []sites.Site {
+	if vars.SiteFilter.IsEmpty() {
+		vars.SitesScanned = len(all)
+		printer.Info("Scanning %d sites", len(all))
+		return all
+	}
+
+	kept, problems := vars.SiteFilter.Apply(all)
+	for _, problem := range problems {
+		printer.Warning("%s", problem)
+	}
+	if len(kept) == 0 {
+		printer.Error("No site is left to scan (%s)", vars.SiteFilter)
+		os.Exit(1)
+	}
+
+	var hosts []string
+	for _, site := range kept {
+		if host := site.Host(); !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	vars.SitesScanned = len(kept)
+	printer.Info("Scanning %d of %d sites (%s): %s", len(kept), len(all), vars.SiteFilter, strings.Join(hosts, ", "))
+	return kept
+}
+
+// ListSites prints the sites of sources.txt, only those of category and with tag when
+// they are set.
+func ListSites(category string, tag string) error {
+	file, err := sourcesFile()
+	if err != nil {
+		return err
+	}
+
+	shown, disabled := 0, 0
+	for _, entry := range file.Entries() {
+		site := entry.Site
+		if category != "" && site.Category() != strings.ToLower(category) {
+			continue
+		}
+		if tag != "" && !site.HasTag(tag) {
+			continue
+		}
+		shown++
+
+		var labels []string
+		if entry.Disabled {
+			disabled++
+			labels = append(labels, "disabled")
+		}
+		if site.Category() != "" {
+			labels = append(labels, "category: "+site.Category())
+		}
+		if tags := site.Tags(); len(tags) > 0 {
+			labels = append(labels, "tags: "+strings.Join(tags, ","))
+		}
+		line := fmt.Sprintf("%-28s %s", site.Host(), site.DisplayURL)
+		if len(labels) > 0 {
+			line += "  (" + strings.Join(labels, "; ") + ")"
+		}
+		fmt.Println(line)
+	}
+	printer.Info("%d site(s), %d disabled, in %s", shown, disabled, file.Path)
+	return nil
+}
+
+// AddSite validates a sources.txt line and appends it. Unless username is empty, the
+// site is first probed: username has to be found on it and its missing username must not be,
+// or the site is only added with force.
+func AddSite(ctx context.Context, line string, username string, force bool) error {
+	site, err := sites.Parse(line)
+	if err != nil {
+		return err
+	}
+	warnings, err := sites.Validate(site)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		printer.Warning("%s", warning)
+	}
+
+	if username != "" && site.KnownUsername() == "" {
+		site.Options["known"] = username
+	}
+
+	file, err := sourcesFile()
+	if err != nil {
+		return err
+	}
+	if err := file.Add(site); err != nil {
+		return err
+	}
+
+	if username != "" {
+		if err := probeNewSite(ctx, site, username); err != nil {
+			if !force {
+				return fmt.Errorf("%w, use --force to add it anyway", err)
+			}
+			printer.Warning("%v, adding it anyway", err)
+		}
+	} else {
+		printer.Warning("Adding %s without probing it", site.ScanURL)
+	}
+
+	if err := file.Save(); err != nil {
+		return err
+	}
+	printer.Success("Added %s to %s", site.ScanURL, file.Path)
+	return nil
+}
+
+// probeNewSite checks that site finds username and not its missing username.
+func probeNewSite(ctx context.Context, site sites.Site, username string) error {
+	prober, err := NewProber(ctx, ProberOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = prober.Close() }()
+
+	printer.Info("Probing %s with '%s'", site.ScanURL, username)
+	result := prober.Probe(ctx, site, username)
+	if result.Outcome != OutcomeFound {
+		return fmt.Errorf("'%s' was not found on %s (%s: %s)", username, result.URL, result.Outcome, result.Reason)
+	}
+	printer.Success("Found '%s' at %s", username, result.URL)
+
+	missing := site.MissingUsername()
+	result = prober.Probe(ctx, site, missing)
+	if result.Outcome == OutcomeFound {
+		return fmt.Errorf("the missing username '%s' was found on %s too, the site would report everyone", missing, result.URL)
+	}
+	printer.Success("'%s' is not found (%s)", missing, result.Reason)
+	return nil
+}
+
+// RemoveSite deletes the site matching query from sources.txt.
+func RemoveSite(query string) error {
+	file, err := sourcesFile()
+	if err != nil {
+		return err
+	}
+	entry, err := file.Remove(query)
+	if err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+	printer.Success("Removed %s", entry.Site.ScanURL)
+	return nil
+}
+
+// SetSiteEnabled comments the site matching query in or out of sources.txt.
+func SetSiteEnabled(query string, enabled bool) error {
+	file, err := sourcesFile()
+	if err != nil {
+		return err
+	}
+	entry, changed, err := file.SetEnabled(query, enabled)
+	if err != nil {
+		return err
+	}
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	if !changed {
+		printer.Info("%s is already %s", entry.Site.ScanURL, state)
+		return nil
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+	printer.Success("%s is now %s", entry.Site.ScanURL, state)
+	return nil
+}
+
+// ImportSites merges the sites of another sources file into sources.txt. Sites already
+// in it are skipped, and disabled ones stay disabled.
+func ImportSites(path string) error {
+	from, err := sites.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	file, err := sourcesFile()
+	if err != nil {
+		return err
+	}
+
+	added, skipped, invalid := 0, 0, 0
+	for _, entry := range from.Entries() {
+		if _, err := sites.Validate(entry.Site); err != nil {
+			printer.Warning("%s line %d: %v", path, entry.Line, err)
+			invalid++
+			continue
+		}
+		if err := file.Add(entry.Site); err != nil {
+			helpers.V("Skipping %s: %v", entry.Site.ScanURL, err)
+			skipped++
+			continue
+		}
+		if entry.Disabled {
+			if _, _, err := file.SetEnabled(entry.Site.ScanURL, false); err != nil {
+				return err
+			}
+		}
+		added++
+	}
+
+	if added > 0 {
+		if err := file.Save(); err != nil {
+			return err
+		}
+	}
+	printer.Success("Imported %d site(s) from %s, skipped %d already present and %d invalid", added, path, skipped, invalid)
+	return nil
+}
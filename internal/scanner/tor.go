@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/proxy"
+	"github.com/KillAllChickens/argus/internal/tor"
+	"github.com/KillAllChickens/argus/internal/vars"
+)
+
+const (
+	defaultTorSOCKS = "127.0.0.1:9050"
+	// Tor ignores NEWNYM more often than this anyway
+	torRotateInterval = 10 * time.Second
+	torControlTimeout = 5 * time.Second
+)
+
+// torCircuits rotates Tor circuits through the control port.
+type torCircuits struct {
+	socks   string          // host:port of the SOCKS port
+	control *tor.Controller // nil if the control port is unavailable
+
+	mu          sync.Mutex
+	requests    int // since the last rotation
+	rotations   int
+	lastRotated time.Time
+}
+
+// setupTor adds Tor's SOCKS port to the proxies and connects to its control port, with
+// --tor. Without the control port Tor is still used, but circuits are never rotated.
+func (s *Session) setupTor() {
+	if !vars.UseTor {
+		return
+	}
+	socks := vars.Tor.SOCKS
+	if socks == "" {
+		socks = defaultTorSOCKS
+	}
+	vars.Proxies = append(vars.Proxies, "socks5://"+socks)
+	s.tor = &torCircuits{socks: socks}
+
+	if vars.Tor.Control == "" {
+		return
+	}
+	control, err := tor.Dial(vars.Tor.Control, torControlTimeout)
+	if err == nil {
+		if err = control.Authenticate(vars.Tor.Password, vars.Tor.CookiePath); err != nil {
+			_ = control.Close()
+		}
+	}
+	if err != nil {
+		printer.Warning("Could not use the Tor control port at %s, circuits will not be rotated: %v", vars.Tor.Control, err)
+		return
+	}
+	s.tor.control = control
+	if vars.Tor.RotateEvery > 0 {
+		printer.Info("Connected to the Tor control port, rotating circuits every %d requests and when blocked", vars.Tor.RotateEvery)
+	} else {
+		printer.Info("Connected to the Tor control port, rotating circuits when blocked")
+	}
+}
+
+// isTor reports whether proxyURL is Tor's SOCKS port.
+func (s *Session) isTor(proxyURL *url.URL) bool {
+	return s.tor != nil && proxyURL != nil && proxyURL.Host == s.tor.socks
+}
+
+// isolateTor gives every site its own Tor circuits: Tor doesn't share circuits between
+// SOCKS connections with different usernames.
+func isolateTor(proxyURL *url.URL, site string) *url.URL {
+	isolated := *proxyURL
+	isolated.User = url.UserPassword("argus-"+site, "argus")
+	return &isolated
+}
+
+// torRequestDone counts a request made through Tor, rotating circuits every
+// RotateEvery requests. Blocked requests rotate them right away, see fetchUnblocked.
+func (s *Session) torRequestDone(ctx context.Context) {
+	used, ok := proxy.FromContext(ctx)
+	if !ok || !s.isTor(used) {
+		return
+	}
+
+	s.tor.mu.Lock()
+	s.tor.requests++
+	due := vars.Tor.RotateEvery > 0 && s.tor.requests >= vars.Tor.RotateEvery
+	s.tor.mu.Unlock()
+	if due {
+		s.rotateTor("after " + strconv.Itoa(vars.Tor.RotateEvery) + " requests")
+	}
+}
+
+// rotateTor asks Tor for new circuits, so the next requests come from a new exit IP. It
+// reports whether the circuits are new, rotated now or less than torRotateInterval ago.
+func (s *Session) rotateTor(reason string) bool {
+	if s.tor == nil || s.tor.control == nil {
+		return false
+	}
+
+	s.tor.mu.Lock()
+	if time.Since(s.tor.lastRotated) < torRotateInterval {
+		s.tor.mu.Unlock()
+		return true
+	}
+	s.tor.lastRotated = time.Now()
+	s.tor.requests = 0
+	s.tor.mu.Unlock()
+
+	if err := s.tor.control.NewCircuits(); err != nil {
+		s.verbose(printer.Error, "Tor: could not rotate circuits: %v", err)
+		return false
+	}
+	// Kept-alive connections would stay on the old circuits
+	if transport, err := s.Client.HTTPTransport(); err == nil {
+		transport.CloseIdleConnections()
+	}
+
+	s.tor.mu.Lock()
+	s.tor.rotations++
+	s.tor.mu.Unlock()
+	s.verbose(printer.Info, "Tor: new circuits %s", reason)
+	return true
+}
+
+// closeTor disconnects from the control port, reporting the rotations with --verbose.
+func (s *Session) closeTor() {
+	if s.tor == nil || s.tor.control == nil {
+		return
+	}
+	if vars.Verbose {
+		printer.Info("Tor: rotated circuits %d times", s.tor.rotations)
+	}
+	_ = s.tor.control.Close()
+}
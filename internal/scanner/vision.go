@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/KillAllChickens/argus/internal/helpers"
+	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/vars"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Confidence of a finding, see vars.FindingInfo.Confidence
+const (
+	confidenceHeuristic  = 0.6 // passed the soft 404 and non-existent user checks
+	confidenceAIVerified = 0.8
+	confidenceUnverified = 0.5 // AI verification failed, kept with --ai-fail-policy open
+	visionBoost          = 0.15
+	visionPenalty        = 0.3
+)
+
+const (
+	maxVisionImageSize = 5 << 20 // 5 MiB
+	maxVisionPageText  = 4000
+)
+
+// checkVision sends the profile picture and the page text to the vision model and
+// adjusts the confidence of the finding with its verdict.
+func (s *Session) checkVision(ctx context.Context, username string, URL string, body string, pfpURL string, info *vars.FindingInfo) {
+	image, mimeType, err := s.downloadImage(ctx, pfpURL)
+	if err != nil {
+		s.verbose(printer.Error, "Could not download profile picture for %s: %v", URL, err)
+		return
+	}
+
+	prompt := fmt.Sprintf("Profile picture URL: %s\n\nPage text:\n%s", pfpURL, pageText(body, maxVisionPageText))
+	populated, err := s.Vision.VerifyWithImage(ctx, replacePlaceholders(vars.PromptVisionCheck, username, URL), prompt, image, mimeType)
+	if err != nil {
+		s.reportAIError(URL, err)
+		return
+	}
+
+	if populated {
+		info.VisionVerdict = "populated"
+		info.Confidence = min(1, info.Confidence+visionBoost)
+	} else {
+		info.VisionVerdict = "placeholder"
+		info.Confidence = max(0, info.Confidence-visionPenalty)
+	}
+	s.verbose(helpers.V, "AI vision says '%s' for %s", info.VisionVerdict, URL)
+}
+
+func (s *Session) downloadImage(ctx context.Context, imageURL string) ([]byte, string, error) {
+	res, err := s.Client.R().
+		SetContext(ctx).
+		SetResponseBodyLimit(maxVisionImageSize).
+		Get(imageURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if res.IsError() {
+		return nil, "", fmt.Errorf("status %d", res.StatusCode())
+	}
+
+	data := res.Bytes()
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, "", fmt.Errorf("not an image (%s)", mimeType)
+	}
+	return data, mimeType, nil
+}
+
+// pageText returns the visible text of an HTML page, whitespace collapsed and cut to limit bytes.
+func pageText(body string, limit int) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	doc.Find("script, style, noscript, template").Remove()
+
+	text := strings.Join(strings.Fields(doc.Find("body").Text()), " ")
+	if len(text) > limit {
+		text = text[:limit]
+	}
+	return text
+}
@@ -0,0 +1,224 @@
+package sites
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// File is a sources.txt kept line by line, so comments, empty lines and the order of
+// the sites survive edits.
+type File struct {
+	Path  string
+	lines []string
+}
+
+// Entry is a site of a File. Disabled sites are commented out with a '#'.
+type Entry struct {
+	Site     Site
+	Disabled bool
+	Line     int // 1-based
+}
+
+// LoadFile reads a sources.txt.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	f := &File{Path: path}
+	if text != "" {
+		f.lines = strings.Split(text, "\n")
+	}
+	return f, nil
+}
+
+// parseLine returns the entry on a line, ok is false for empty lines and comments that
+// are not a commented out site.
+func parseLine(line string) (Entry, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return Entry{}, false
+	}
+	if rest, disabled := strings.CutPrefix(line, "#"); disabled {
+		rest = strings.TrimSpace(rest)
+		if !strings.Contains(rest, "{U}") || !(strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://")) {
+			return Entry{}, false
+		}
+		site, err := Parse(rest)
+		if err != nil {
+			return Entry{}, false
+		}
+		return Entry{Site: site, Disabled: true}, true
+	}
+	site, err := Parse(line)
+	if err != nil {
+		return Entry{}, false
+	}
+	return Entry{Site: site}, true
+}
+
+// Entries returns every site of the file, enabled or not, in file order.
+func (f *File) Entries() []Entry {
+	var entries []Entry
+	for i, line := range f.lines {
+		if entry, ok := parseLine(line); ok {
+			entry.Line = i + 1
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Find returns the entry matching query: its scan or display URL, or the host of the
+// site (github.com, bandcamp.com). It fails if no entry or more than one matches.
+func (f *File) Find(query string) (Entry, error) {
+	var matches []Entry
+	for _, entry := range f.Entries() {
+		if entry.Site.ScanURL == query || entry.Site.DisplayURL == query {
+			return entry, nil
+		}
+		if entry.Site.matchesHost(query) {
+			matches = append(matches, entry)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Entry{}, fmt.Errorf("no site matches %q", query)
+	case 1:
+		return matches[0], nil
+	default:
+		var urls []string
+		for _, match := range matches {
+			urls = append(urls, match.Site.ScanURL)
+		}
+		return Entry{}, fmt.Errorf("%q matches %d sites, use the full URL: %s", query, len(matches), strings.Join(urls, ", "))
+	}
+}
+
+// Host is the domain of the site without "www." or a {U} subdomain, "" if the URL is
+// invalid.
+func (s Site) Host() string {
+	u, err := url.Parse(strings.ReplaceAll(s.DisplayURL, "{U}", "argus-username"))
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "argus-username.")
+	return strings.TrimPrefix(host, "www.")
+}
+
+func (s Site) matchesHost(query string) bool {
+	query = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(query)), "www.")
+	return query != "" && query == s.Host()
+}
+
+// Add appends site to the file. It fails if a site with the same scan URL is in it,
+// enabled or not.
+func (f *File) Add(site Site) error {
+	for _, entry := range f.Entries() {
+		if entry.Site.ScanURL == site.ScanURL {
+			state := "already"
+			if entry.Disabled {
+				state = "already (disabled)"
+			}
+			return fmt.Errorf("%s is %s in %s, line %d", site.ScanURL, state, filepath.Base(f.Path), entry.Line)
+		}
+	}
+	f.lines = append(f.lines, site.String())
+	return nil
+}
+
+// Remove deletes the site matching query.
+func (f *File) Remove(query string) (Entry, error) {
+	entry, err := f.Find(query)
+	if err != nil {
+		return Entry{}, err
+	}
+	f.lines = append(f.lines[:entry.Line-1], f.lines[entry.Line:]...)
+	return entry, nil
+}
+
+// SetEnabled comments the site matching query out, or back in. changed is false if it
+// already was in that state.
+func (f *File) SetEnabled(query string, enabled bool) (entry Entry, changed bool, err error) {
+	entry, err = f.Find(query)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if entry.Disabled != enabled {
+		return entry, false, nil
+	}
+	line := strings.TrimSpace(f.lines[entry.Line-1])
+	if enabled {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+	} else {
+		line = "#" + line
+	}
+	f.lines[entry.Line-1] = line
+	entry.Disabled = !enabled
+	return entry, true, nil
+}
+
+// Save writes the file back, through a temporary file so it is never left half written.
+func (f *File) Save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), ".sources-*.txt")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.WriteString(strings.Join(f.lines, "\n") + "\n"); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(f.Path); err == nil {
+		_ = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// Options the scanner understands, besides header.<Name>
+var knownOptions = []string{"render", "wait", "pfp", "pfp_attr", "browser", "category", "tags", "known", "missing"}
+
+// Validate checks that the URLs of site are usable templates. warnings lists options the
+// scanner does not know, which are most likely typos.
+func Validate(site Site) (warnings []string, err error) {
+	for _, template := range []string{site.ScanURL, site.DisplayURL} {
+		if !strings.Contains(template, "{U}") {
+			return nil, fmt.Errorf("%s has no {U} placeholder for the username", template)
+		}
+		u, err := url.Parse(strings.ReplaceAll(template, "{U}", "username"))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid URL: %w", template, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("%s is not an http(s) URL", template)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("%s has no host", template)
+		}
+	}
+
+	var keys []string
+	for key := range site.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if strings.HasPrefix(key, "header.") && key != "header." {
+			continue
+		}
+		if !slices.Contains(knownOptions, key) {
+			warnings = append(warnings, fmt.Sprintf("unknown option %q", key))
+		}
+	}
+	return warnings, nil
+}
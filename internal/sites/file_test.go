@@ -0,0 +1,180 @@
+package sites
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSources = `# Sources for argus
+# https://docs.example.com/{U} is only an example, not a site
+
+## Development
+https://github.com/{U} category=dev
+#https://gitlab.com/{U} category=dev
+
+https://api.example.com/{U}|https://example.com/{U} render=true wait="div.profile header"
+`
+
+func writeSources(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sources.txt")
+	if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadSources(t *testing.T, path string) *File {
+	t.Helper()
+	f, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func readSources(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileEntries(t *testing.T) {
+	f := loadSources(t, writeSources(t, testSources))
+	entries := f.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries %+v, want 3", len(entries), entries)
+	}
+	want := []struct {
+		url      string
+		disabled bool
+		line     int
+	}{
+		{"https://github.com/{U}", false, 5},
+		{"https://gitlab.com/{U}", true, 6},
+		{"https://api.example.com/{U}", false, 8},
+	}
+	for i, w := range want {
+		if e := entries[i]; e.Site.ScanURL != w.url || e.Disabled != w.disabled || e.Line != w.line {
+			t.Errorf("entry %d = %s (disabled %t, line %d), want %s (disabled %t, line %d)", i, e.Site.ScanURL, e.Disabled, e.Line, w.url, w.disabled, w.line)
+		}
+	}
+}
+
+func TestFileFind(t *testing.T) {
+	f := loadSources(t, writeSources(t, testSources+"https://gist.github.com/{U}\nhttps://{U}.github.io\n"))
+	tests := map[string]string{
+		"github.com":                  "https://github.com/{U}",
+		"www.GitHub.com":              "https://github.com/{U}",
+		"https://example.com/{U}":     "https://api.example.com/{U}",
+		"example.com":                 "https://api.example.com/{U}",
+		"gitlab.com":                  "https://gitlab.com/{U}",
+		"github.io":                   "https://{U}.github.io",
+		"https://gist.github.com/{U}": "https://gist.github.com/{U}",
+	}
+	for query, want := range tests {
+		entry, err := f.Find(query)
+		if err != nil || entry.Site.ScanURL != want {
+			t.Errorf("Find(%q) = %s, %v, want %s", query, entry.Site.ScanURL, err, want)
+		}
+	}
+	if _, err := f.Find("docs.example.com"); err == nil {
+		t.Error("Find matched a site in a comment")
+	}
+	if _, err := f.Find("unknown.com"); err == nil {
+		t.Error("Find(unknown.com) found a site")
+	}
+}
+
+func TestFileEdits(t *testing.T) {
+	path := writeSources(t, testSources)
+	f := loadSources(t, path)
+
+	if _, changed, err := f.SetEnabled("gitlab.com", true); err != nil || !changed {
+		t.Fatalf("enable gitlab.com: changed %t, %v", changed, err)
+	}
+	if _, changed, err := f.SetEnabled("github.com", true); err != nil || changed {
+		t.Errorf("enable the enabled github.com: changed %t, %v", changed, err)
+	}
+	if entry, changed, err := f.SetEnabled("example.com", false); err != nil || !changed || !entry.Disabled {
+		t.Fatalf("disable example.com: %+v, changed %t, %v", entry, changed, err)
+	}
+	if entry, err := f.Remove("github.com"); err != nil || entry.Line != 5 {
+		t.Fatalf("remove github.com: %+v, %v", entry, err)
+	}
+	if _, err := f.Remove("github.com"); err == nil {
+		t.Error("removed github.com twice")
+	}
+
+	site, err := Parse(`https://codeberg.org/{U} category=dev wait="main header"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Add(site); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Add(Site{ScanURL: "https://api.example.com/{U}"}); err == nil || !strings.Contains(err.Error(), "already (disabled)") || !strings.Contains(err.Error(), "line 7") {
+		t.Errorf("adding a disabled site again: %v", err)
+	}
+
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	want := `# Sources for argus
+# https://docs.example.com/{U} is only an example, not a site
+
+## Development
+https://gitlab.com/{U} category=dev
+
+#https://api.example.com/{U}|https://example.com/{U} render=true wait="div.profile header"
+https://codeberg.org/{U} category=dev wait="main header"
+`
+	if got := readSources(t, path); got != want {
+		t.Errorf("saved file:\n%s\nwant:\n%s", got, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("saved file mode %v, %v, want 0640", info.Mode().Perm(), err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".sources-*")); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+
+	// Everything survives a reload
+	entries := loadSources(t, path).Entries()
+	if len(entries) != 3 || entries[2].Site.Options["wait"] != "main header" || !entries[1].Disabled {
+		t.Errorf("reloaded entries = %+v", entries)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		line     string
+		warnings int
+		wantErr  bool
+	}{
+		{line: "https://github.com/{U} category=dev tags=git known=torvalds header.Accept=text/html"},
+		{line: "https://api.example.com/{U}|https://example.com/{U} render=true wait=main"},
+		{line: "https://github.com/{U} categroy=dev header.=x", warnings: 2},
+		{line: "https://github.com/", wantErr: true},
+		{line: "https://api.example.com/{U}|https://example.com/", wantErr: true},
+		{line: "ftp://example.com/{U}", wantErr: true},
+		{line: "https:///{U}", wantErr: true},
+		{line: "https://exa%mple.com/{U}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		site, err := Parse(tt.line)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.line, err)
+		}
+		warnings, err := Validate(site)
+		if (err != nil) != tt.wantErr || len(warnings) != tt.warnings {
+			t.Errorf("Validate(%q) = %q, %v, want %d warnings and error %t", tt.line, warnings, err, tt.warnings, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package sites
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Filter narrows the sites of a scan. Tags match the category and the tags of a site,
+// sites match by URL or domain (github.com).
+type Filter struct {
+	IncludeTags  []string `json:"include_tags,omitempty"`
+	ExcludeTags  []string `json:"exclude_tags,omitempty"`
+	Sites        []string `json:"sites,omitempty"`
+	ExcludeSites []string `json:"exclude_sites,omitempty"`
+}
+
+// IsEmpty reports whether the filter keeps every site.
+func (f Filter) IsEmpty() bool {
+	return len(f.IncludeTags) == 0 && len(f.ExcludeTags) == 0 && len(f.Sites) == 0 && len(f.ExcludeSites) == 0
+}
+
+// String describes the filter, e.g. "include tags: dev, gaming; exclude sites: gitlab.com".
+func (f Filter) String() string {
+	var parts []string
+	for _, part := range []struct {
+		name   string
+		values []string
+	}{
+		{"include tags", f.IncludeTags},
+		{"exclude tags", f.ExcludeTags},
+		{"sites", f.Sites},
+		{"exclude sites", f.ExcludeSites},
+	} {
+		if len(part.values) > 0 {
+			parts = append(parts, part.name+": "+strings.Join(part.values, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Labels are the category and the tags of the site.
+func (s Site) Labels() []string {
+	labels := s.Tags()
+	if category := s.Category(); category != "" {
+		labels = append([]string{category}, labels...)
+	}
+	return labels
+}
+
+// hasLabel reports whether the site has one of labels as category or tag.
+func (s Site) hasLabel(labels []string) bool {
+	for _, label := range s.Labels() {
+		for _, want := range labels {
+			if label == strings.ToLower(strings.TrimSpace(want)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches reports whether query is the scan or display URL of the site, or its domain.
+func (s Site) Matches(query string) bool {
+	return s.ScanURL == query || s.DisplayURL == query || s.matchesHost(query)
+}
+
+func (s Site) matchesAny(queries []string) bool {
+	for _, query := range queries {
+		if s.Matches(query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the sites the filter keeps, in order. problems lists the tags and sites
+// of the filter that match no site, most likely typos.
+func (f Filter) Apply(all []Site) (kept []Site, problems []string) {
+	for _, site := range all {
+		if len(f.Sites) > 0 && !site.matchesAny(f.Sites) {
+			continue
+		}
+		if len(f.IncludeTags) > 0 && !site.hasLabel(f.IncludeTags) {
+			continue
+		}
+		if site.hasLabel(f.ExcludeTags) || site.matchesAny(f.ExcludeSites) {
+			continue
+		}
+		kept = append(kept, site)
+	}
+
+	labels := AllLabels(all)
+	for _, tag := range append(append([]string{}, f.IncludeTags...), f.ExcludeTags...) {
+		if !slices.Contains(labels, strings.ToLower(strings.TrimSpace(tag))) {
+			problems = append(problems, fmt.Sprintf("no site has the tag or category %q", tag))
+		}
+	}
+	for _, query := range append(append([]string{}, f.Sites...), f.ExcludeSites...) {
+		if !slices.ContainsFunc(all, func(site Site) bool { return site.Matches(query) }) {
+			problems = append(problems, fmt.Sprintf("no site matches %q", query))
+		}
+	}
+	return kept, problems
+}
+
+// AllLabels returns every category and tag of sites, sorted.
+func AllLabels(all []Site) []string {
+	seen := make(map[string]bool)
+	var labels []string
+	for _, site := range all {
+		for _, label := range site.Labels() {
+			if !seen[label] {
+				seen[label] = true
+				labels = append(labels, label)
+			}
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
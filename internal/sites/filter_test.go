@@ -0,0 +1,103 @@
+package sites
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFilterApply(t *testing.T) {
+	var all []Site
+	for _, line := range []string{
+		"https://github.com/{U} category=dev tags=git",
+		"https://gitlab.com/{U} category=dev tags=git",
+		"https://www.chess.com/member/{U} category=gaming tags=chess",
+		"https://steamcommunity.com/id/{U} category=gaming tags=Steam,dev",
+		"https://api.example.com/users/{U}|https://example.com/{U} category=social",
+		"https://{U}.github.io category=blog",
+	} {
+		site, err := Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, site)
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		want     []string // hosts of the kept sites, in order
+		problems []string
+	}{
+		{
+			name: "empty filter keeps everything",
+			want: []string{"github.com", "gitlab.com", "chess.com", "steamcommunity.com", "example.com", "github.io"},
+		},
+		{
+			name:   "category as a tag",
+			filter: Filter{IncludeTags: []string{"gaming"}},
+			want:   []string{"chess.com", "steamcommunity.com"},
+		},
+		{
+			name:   "tags match the category or tags, case insensitive",
+			filter: Filter{IncludeTags: []string{" DEV", "steam"}},
+			want:   []string{"github.com", "gitlab.com", "steamcommunity.com"},
+		},
+		{
+			name:   "exclude tags win over include tags",
+			filter: Filter{IncludeTags: []string{"dev"}, ExcludeTags: []string{"gaming"}},
+			want:   []string{"github.com", "gitlab.com"},
+		},
+		{
+			name:   "exclude sites win over include tags",
+			filter: Filter{IncludeTags: []string{"git"}, ExcludeSites: []string{"gitlab.com"}},
+			want:   []string{"github.com"},
+		},
+		{
+			name:   "sites and tags both have to match",
+			filter: Filter{Sites: []string{"github.com", "chess.com"}, IncludeTags: []string{"dev"}},
+			want:   []string{"github.com"},
+		},
+		{
+			name:   "exclude sites win over sites",
+			filter: Filter{Sites: []string{"github.com", "gitlab.com"}, ExcludeSites: []string{"github.com"}},
+			want:   []string{"gitlab.com"},
+		},
+		{
+			name:   "sites match the host, without www. or a {U} subdomain",
+			filter: Filter{Sites: []string{"www.chess.com", "GitHub.io", "example.com"}},
+			want:   []string{"chess.com", "example.com", "github.io"},
+		},
+		{
+			name:   "sites match the scan or display URL",
+			filter: Filter{Sites: []string{"https://api.example.com/users/{U}", "https://github.com/{U}"}},
+			want:   []string{"github.com", "example.com"},
+		},
+		{
+			name:     "a host is not matched by a URL or a part of it",
+			filter:   Filter{Sites: []string{"https://github.com/", "git", "api.example.com"}},
+			problems: []string{`no site matches "https://github.com/"`, `no site matches "git"`, `no site matches "api.example.com"`},
+		},
+		{
+			name:     "typos are problems",
+			filter:   Filter{IncludeTags: []string{"gamign", "dev"}, ExcludeTags: []string{"nsfw"}, Sites: []string{"githb.com", "github.com"}, ExcludeSites: []string{"gitlab.org"}},
+			want:     []string{"github.com"},
+			problems: []string{`no site has the tag or category "gamign"`, `no site has the tag or category "nsfw"`, `no site matches "githb.com"`, `no site matches "gitlab.org"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, problems := tt.filter.Apply(all)
+			var got []string
+			for _, site := range kept {
+				got = append(got, site.Host())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(problems, tt.problems) {
+				t.Errorf("problems %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
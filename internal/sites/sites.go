@@ -0,0 +1,257 @@
+package sites
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Site is a single entry of sources.txt:
+//
+//	<scan url>[|<display url>] [key=value ...]
+//
+// Values containing spaces can be quoted: wait="div.profile header"
+type Site struct {
+	ScanURL    string // requested URL, {U} is replaced by the username
+	DisplayURL string // URL shown in the results, same as ScanURL when not aliased
+	Options    map[string]string
+}
+
+// Parse parses a single (non comment) line of sources.txt.
+func Parse(line string) (Site, error) {
+	fields, err := splitFields(strings.TrimSpace(line))
+	if err != nil {
+		return Site{}, err
+	}
+	if len(fields) == 0 {
+		return Site{}, fmt.Errorf("empty site definition")
+	}
+
+	site := Site{Options: make(map[string]string)}
+
+	urls := strings.Split(fields[0], "|")
+	if len(urls) > 2 {
+		return Site{}, fmt.Errorf("%q has more than one '|'", fields[0])
+	}
+	site.ScanURL = urls[0]
+	site.DisplayURL = urls[len(urls)-1]
+
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return Site{}, fmt.Errorf("option %q is not in key=value form", field)
+		}
+		site.Options[strings.ToLower(key)] = value
+	}
+
+	return site, nil
+}
+
+// String formats the site back into its sources.txt form.
+func (s Site) String() string {
+	var b strings.Builder
+	b.WriteString(s.ScanURL)
+	if s.DisplayURL != "" && s.DisplayURL != s.ScanURL {
+		b.WriteString("|")
+		b.WriteString(s.DisplayURL)
+	}
+
+	keys := make([]string, 0, len(s.Options))
+	for key := range s.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := s.Options[key]
+		if value == "" || strings.ContainsAny(value, " \t\"") {
+			value = quote(value)
+		}
+		b.WriteString(" " + key + "=" + value)
+	}
+	return b.String()
+}
+
+// quote quotes value for splitFields, which only knows the \" and \\ escapes.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Option returns a raw option value, or "" if it is not set.
+func (s Site) Option(key string) string {
+	return s.Options[key]
+}
+
+// BoolOption reports whether an option is set to a true value (true, yes, 1).
+func (s Site) BoolOption(key string) bool {
+	v, err := strconv.ParseBool(s.Options[key])
+	if err != nil {
+		return strings.EqualFold(s.Options[key], "yes")
+	}
+	return v
+}
+
+// Render reports whether the site has to be fetched with a headless browser (render=true).
+func (s Site) Render() bool {
+	return s.BoolOption("render")
+}
+
+// WaitFor is the CSS selector to wait for before reading a rendered page (wait=...).
+// When empty, the renderer waits for the network to go idle.
+func (s Site) WaitFor() string {
+	return s.Options["wait"]
+}
+
+// PFPSelector is the CSS selector of the profile picture on this site (pfp=...), and the
+// attribute holding its URL (pfp_attr=...), empty to guess it from the element.
+func (s Site) PFPSelector() (selector string, attr string) {
+	return s.Options["pfp"], s.Options["pfp_attr"]
+}
+
+// Browser is the browser profile or family this site is always requested as
+// (browser=...), "" to use the run's.
+func (s Site) Browser() string {
+	return s.Options["browser"]
+}
+
+// Headers are the request headers set with header.<Name>=<value>, which override the
+// browser profile's. An empty value removes the header.
+func (s Site) Headers() map[string]string {
+	var headers map[string]string
+	for key, value := range s.Options {
+		name, ok := strings.CutPrefix(key, "header.")
+		if !ok || name == "" {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers
+}
+
+// DefaultMissingUsername is the username sites check expects not to be found, on sites
+// without a missing= option.
+const DefaultMissingUsername = "argusmissinguser7q"
+
+// KnownUsername is a username that exists on the site (known=...), "" when not set.
+func (s Site) KnownUsername() string {
+	return s.Options["known"]
+}
+
+// MissingUsername is a username that never exists on the site (missing=...).
+func (s Site) MissingUsername() string {
+	if missing := s.Options["missing"]; missing != "" {
+		return missing
+	}
+	return DefaultMissingUsername
+}
+
+// Category is the kind of site, e.g. social or gaming (category=...), "" when not set.
+func (s Site) Category() string {
+	return strings.ToLower(strings.TrimSpace(s.Options["category"]))
+}
+
+// Tags are the labels of the site, comma separated in tags=...
+func (s Site) Tags() []string {
+	var tags []string
+	for _, tag := range strings.Split(s.Options["tags"], ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// HasTag reports whether the site has tag.
+func (s Site) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, t := range s.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// usernamePattern is what {U} matches when looking for usernames in URLs
+const usernamePattern = `([A-Za-z0-9_.\-]+)`
+
+// MatchUsername returns the username in rawURL if it is a profile URL of this site,
+// comparing against both the display and the scan URL. Scheme, "www." and a trailing
+// slash are ignored.
+func (s Site) MatchUsername(rawURL string) (string, bool) {
+	for _, template := range []string{s.DisplayURL, s.ScanURL} {
+		if !strings.Contains(template, "{U}") {
+			continue
+		}
+		target := rawURL
+		if !strings.Contains(template, "?") {
+			target, _, _ = strings.Cut(target, "?")
+		}
+		target, _, _ = strings.Cut(target, "#")
+		target = comparableURL(target)
+
+		pattern := regexp.QuoteMeta(comparableURL(template))
+		pattern = strings.Replace(pattern, regexp.QuoteMeta("{U}"), usernamePattern, 1)
+		pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta("{U}"), `[A-Za-z0-9_.\-]+`)
+		re, err := regexp.Compile("(?i)^" + pattern + "$")
+		if err != nil {
+			continue
+		}
+		if match := re.FindStringSubmatch(target); match != nil {
+			return match[1], true
+		}
+	}
+	return "", false
+}
+
+func comparableURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if i := strings.Index(rawURL, "://"); i != -1 {
+		rawURL = rawURL[i+3:]
+	}
+	if len(rawURL) >= 4 && strings.EqualFold(rawURL[:4], "www.") {
+		rawURL = rawURL[4:]
+	}
+	return strings.TrimRight(rawURL, "/")
+}
+
+// splitFields splits on whitespace, keeping double quoted values together.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inQuotes := false
+	hasField := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && inQuotes && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+		case c == '"':
+			inQuotes = !inQuotes
+			hasField = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if hasField {
+				fields = append(fields, current.String())
+				current.Reset()
+				hasField = false
+			}
+		default:
+			current.WriteByte(c)
+			hasField = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if hasField {
+		fields = append(fields, current.String())
+	}
+	return fields, nil
+}
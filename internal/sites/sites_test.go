@@ -0,0 +1,83 @@
+package sites
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    Site
+		wantErr bool
+	}{
+		{
+			line: "https://github.com/{U}",
+			want: Site{ScanURL: "https://github.com/{U}", DisplayURL: "https://github.com/{U}", Options: map[string]string{}},
+		},
+		{
+			line: `https://api.example.com/users/{U}|https://example.com/{U}  Render=true wait="div.profile header" category=social`,
+			want: Site{ScanURL: "https://api.example.com/users/{U}", DisplayURL: "https://example.com/{U}", Options: map[string]string{
+				"render": "true", "wait": "div.profile header", "category": "social",
+			}},
+		},
+		{
+			line: `https://example.com/{U} pfp="img[alt=\"avatar\"]" header.Accept="" path=C:\dir`,
+			want: Site{ScanURL: "https://example.com/{U}", DisplayURL: "https://example.com/{U}", Options: map[string]string{
+				"pfp": `img[alt="avatar"]`, "header.accept": "", "path": `C:\dir`,
+			}},
+		},
+		{line: "", wantErr: true},
+		{line: "https://a/{U}|https://b/{U}|https://c/{U}", wantErr: true},
+		{line: "https://a/{U} render", wantErr: true},
+		{line: "https://a/{U} =true", wantErr: true},
+		{line: `https://a/{U} wait="div`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %t", tt.line, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (got.ScanURL != tt.want.ScanURL || got.DisplayURL != tt.want.DisplayURL || !maps.Equal(got.Options, tt.want.Options)) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	sites := []Site{
+		{ScanURL: "https://github.com/{U}", DisplayURL: "https://github.com/{U}", Options: map[string]string{}},
+		{ScanURL: "https://api.example.com/{U}", DisplayURL: "https://example.com/{U}", Options: map[string]string{"category": "dev"}},
+		{ScanURL: "https://example.com/{U}", DisplayURL: "https://example.com/{U}", Options: map[string]string{
+			"wait":          "div.profile header",
+			"pfp":           `img[alt="avatar"]`,
+			"header.accept": "",
+			"path":          `C:\dir\`,
+			"quoted":        `say "hi" \ bye`,
+			"tab":           "a\tb",
+			"unicode":       "café ✓",
+			"newline_like":  `a\nb`,
+		}},
+	}
+
+	for _, site := range sites {
+		line := site.String()
+		got, err := Parse(line)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", line, err)
+			continue
+		}
+		if got.ScanURL != site.ScanURL || got.DisplayURL != site.DisplayURL || !maps.Equal(got.Options, site.Options) {
+			t.Errorf("%q parsed back as %+v, want %+v", line, got, site)
+		}
+		if again := got.String(); again != line {
+			t.Errorf("String() is not stable: %q then %q", line, again)
+		}
+	}
+
+	if got, want := sites[1].String(), "https://api.example.com/{U}|https://example.com/{U} category=dev"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+// Package tor talks to the Tor control port, to authenticate and ask for new circuits.
+package tor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keys of the SAFECOOKIE HMACs, from the control protocol spec
+const (
+	safeCookieServerKey = "Tor safe cookie authentication server-to-controller hash"
+	safeCookieClientKey = "Tor safe cookie authentication controller-to-server hash"
+)
+
+// Controller is a connection to the control port. It is safe for concurrent use.
+type Controller struct {
+	mu   sync.Mutex
+	conn *textproto.Conn
+}
+
+// Dial connects to the control port at addr (host:port).
+func Dial(addr string, timeout time.Duration) (*Controller, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Controller{conn: textproto.NewConn(conn)}, nil
+}
+
+// Close closes the connection.
+func (c *Controller) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.Close()
+}
+
+// command sends a command and returns the reply, an error unless it is 250.
+func (c *Controller) command(format string, args ...any) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, err := c.conn.Cmd(format, args...)
+	if err != nil {
+		return "", err
+	}
+	c.conn.StartResponse(id)
+	defer c.conn.EndResponse(id)
+	_, msg, err := c.conn.ReadResponse(250)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", strings.Fields(format)[0], err)
+	}
+	return msg, nil
+}
+
+// Authenticate logs in with password when it is set, otherwise with the auth cookie
+// (from cookiePath, or the file Tor reports when empty), or without credentials if
+// Tor allows it.
+func (c *Controller) Authenticate(password string, cookiePath string) error {
+	info, err := c.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	methods, cookieFile := parseProtocolInfo(info)
+	if cookiePath == "" {
+		cookiePath = cookieFile
+	}
+
+	switch {
+	case password != "":
+		_, err = c.command("AUTHENTICATE %s", strconv.Quote(password))
+	case methods["NULL"]:
+		_, err = c.command("AUTHENTICATE")
+	case methods["SAFECOOKIE"] || methods["COOKIE"]:
+		if cookiePath == "" {
+			return fmt.Errorf("tor did not say where its auth cookie is, set cookie_path")
+		}
+		cookie, readErr := os.ReadFile(cookiePath)
+		if readErr != nil {
+			return fmt.Errorf("could not read the auth cookie: %w", readErr)
+		}
+		if methods["SAFECOOKIE"] {
+			err = c.safeCookie(cookie)
+		} else {
+			_, err = c.command("AUTHENTICATE %s", hex.EncodeToString(cookie))
+		}
+	case methods["HASHEDPASSWORD"]:
+		return fmt.Errorf("the control port needs a password")
+	default:
+		return fmt.Errorf("no supported authentication method (tor offers %s)", strings.Join(keys(methods), ", "))
+	}
+	return err
+}
+
+// safeCookie proves knowledge of the cookie without sending it, so a fake control port
+// can't steal it.
+func (c *Controller) safeCookie(cookie []byte) error {
+	clientNonce := make([]byte, 32)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+	reply, err := c.command("AUTHCHALLENGE SAFECOOKIE %s", hex.EncodeToString(clientNonce))
+	if err != nil {
+		return err
+	}
+	fields := parseKeyValues(reply)
+	serverHash, err1 := hex.DecodeString(fields["SERVERHASH"])
+	serverNonce, err2 := hex.DecodeString(fields["SERVERNONCE"])
+	if err1 != nil || err2 != nil || len(serverHash) == 0 || len(serverNonce) == 0 {
+		return fmt.Errorf("invalid AUTHCHALLENGE reply %q", reply)
+	}
+
+	message := append(append(append([]byte{}, cookie...), clientNonce...), serverNonce...)
+	if !hmac.Equal(serverHash, mac(safeCookieServerKey, message)) {
+		return fmt.Errorf("the control port does not know the auth cookie")
+	}
+	_, err = c.command("AUTHENTICATE %s", hex.EncodeToString(mac(safeCookieClientKey, message)))
+	return err
+}
+
+// NewCircuits sends SIGNAL NEWNYM: new streams get new circuits, and a new exit.
+func (c *Controller) NewCircuits() error {
+	_, err := c.command("SIGNAL NEWNYM")
+	return err
+}
+
+func mac(key string, message []byte) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// parseProtocolInfo reads the auth methods and cookie file from a PROTOCOLINFO reply.
+func parseProtocolInfo(reply string) (map[string]bool, string) {
+	methods := make(map[string]bool)
+	cookieFile := ""
+	for _, line := range strings.Split(reply, "\n") {
+		rest, ok := strings.CutPrefix(line, "AUTH ")
+		if !ok {
+			continue
+		}
+		fields := parseKeyValues(rest)
+		for _, method := range strings.Split(fields["METHODS"], ",") {
+			if method != "" {
+				methods[method] = true
+			}
+		}
+		cookieFile = fields["COOKIEFILE"]
+	}
+	return methods, cookieFile
+}
+
+// parseKeyValues parses KEY=value KEY="quoted value" pairs.
+func parseKeyValues(line string) map[string]string {
+	values := make(map[string]string)
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok || strings.Contains(key, " ") {
+			// a word without a value, skip it
+			_, line, _ = strings.Cut(line, " ")
+			continue
+		}
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return values
+			}
+			values[key], _ = strconv.Unquote(quoted)
+			line = rest[len(quoted):]
+			continue
+		}
+		value, after, _ := strings.Cut(rest, " ")
+		values[key] = value
+		line = after
+	}
+	return values
+}
+
+func keys(m map[string]bool) []string {
+	var out []string
+	for key := range m {
+		out = append(out, key)
+	}
+	return out
+}
@@ -0,0 +1,265 @@
+package tor
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeControlPort answers the commands of one connection with reply, and records them.
+type fakeControlPort struct {
+	t        *testing.T
+	listener net.Listener
+	reply    func(cmd string) string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeControlPort(t *testing.T, reply func(cmd string) string) *fakeControlPort {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeControlPort{t: t, listener: listener, reply: reply}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			cmd := scanner.Text()
+			f.mu.Lock()
+			f.commands = append(f.commands, cmd)
+			f.mu.Unlock()
+			if _, err := conn.Write([]byte(f.reply(cmd))); err != nil {
+				return
+			}
+		}
+	}()
+	return f
+}
+
+func (f *fakeControlPort) dial() *Controller {
+	f.t.Helper()
+	c, err := Dial(f.listener.Addr().String(), time.Second)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// sent returns the commands received so far that start with prefix.
+func (f *fakeControlPort) sent(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, cmd := range f.commands {
+		if strings.HasPrefix(cmd, prefix) {
+			out = append(out, cmd)
+		}
+	}
+	return out
+}
+
+func protocolInfo(auth string) string {
+	return "250-PROTOCOLINFO 1\r\n250-" + auth + "\r\n250-VERSION Tor=\"0.4.8.12\"\r\n250 OK\r\n"
+}
+
+func writeCookie(t *testing.T) (string, []byte) {
+	t.Helper()
+	cookie := []byte("0123456789abcdef0123456789abcdef")
+	path := filepath.Join(t.TempDir(), "control_auth_cookie")
+	if err := os.WriteFile(path, cookie, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, cookie
+}
+
+func TestParseProtocolInfo(t *testing.T) {
+	reply := "PROTOCOLINFO 1\nAUTH METHODS=COOKIE,SAFECOOKIE,HASHEDPASSWORD COOKIEFILE=\"/var/run/tor/control auth \\\"cookie\\\"\"\nVERSION Tor=\"0.4.8.12\"\nOK"
+	methods, cookieFile := parseProtocolInfo(reply)
+	for _, method := range []string{"COOKIE", "SAFECOOKIE", "HASHEDPASSWORD"} {
+		if !methods[method] {
+			t.Errorf("method %s is missing from %v", method, methods)
+		}
+	}
+	if len(methods) != 3 {
+		t.Errorf("methods = %v, want 3", methods)
+	}
+	if want := `/var/run/tor/control auth "cookie"`; cookieFile != want {
+		t.Errorf("cookie file = %q, want %q", cookieFile, want)
+	}
+
+	methods, cookieFile = parseProtocolInfo("PROTOCOLINFO 1\nAUTH METHODS=NULL\nOK")
+	if !methods["NULL"] || len(methods) != 1 || cookieFile != "" {
+		t.Errorf("parseProtocolInfo(NULL) = %v, %q", methods, cookieFile)
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	tests := []struct {
+		line string
+		want map[string]string
+	}{
+		{`METHODS=NULL`, map[string]string{"METHODS": "NULL"}},
+		{`AUTHCHALLENGE SERVERHASH=ab12 SERVERNONCE=cd34`, map[string]string{"SERVERHASH": "ab12", "SERVERNONCE": "cd34"}},
+		{`COOKIEFILE="C:\\Tor\\control_auth_cookie" A=b`, map[string]string{"COOKIEFILE": `C:\Tor\control_auth_cookie`, "A": "b"}},
+		{`KEY="with spaces and = signs" NEXT=1`, map[string]string{"KEY": "with spaces and = signs", "NEXT": "1"}},
+		{`Tor="0.4.8.12" word   EMPTY=`, map[string]string{"Tor": "0.4.8.12", "EMPTY": ""}},
+		{`BROKEN="unterminated OTHER=1`, map[string]string{}},
+		{``, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		got := parseKeyValues(tt.line)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseKeyValues(%q) = %q, want %q", tt.line, got, tt.want)
+			continue
+		}
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("parseKeyValues(%q)[%s] = %q, want %q", tt.line, key, got[key], value)
+			}
+		}
+	}
+}
+
+func TestAuthenticateNull(t *testing.T) {
+	port := newFakeControlPort(t, func(cmd string) string {
+		if strings.HasPrefix(cmd, "PROTOCOLINFO") {
+			return protocolInfo("AUTH METHODS=NULL")
+		}
+		return "250 OK\r\n"
+	})
+	if err := port.dial().Authenticate("", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := port.sent("AUTHENTICATE"); len(got) != 1 || got[0] != "AUTHENTICATE" {
+		t.Errorf("sent %q, want a bare AUTHENTICATE", got)
+	}
+}
+
+func TestAuthenticateCookie(t *testing.T) {
+	path, cookie := writeCookie(t)
+	port := newFakeControlPort(t, func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "PROTOCOLINFO"):
+			return protocolInfo(`AUTH METHODS=COOKIE COOKIEFILE="` + path + `"`)
+		case cmd == "AUTHENTICATE "+hex.EncodeToString(cookie):
+			return "250 OK\r\n"
+		}
+		return "515 Authentication failed\r\n"
+	})
+	// The cookie file comes from PROTOCOLINFO
+	if err := port.dial().Authenticate("", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthenticateCookieMissingPath(t *testing.T) {
+	port := newFakeControlPort(t, func(cmd string) string {
+		return protocolInfo("AUTH METHODS=COOKIE")
+	})
+	if err := port.dial().Authenticate("", ""); err == nil || !strings.Contains(err.Error(), "cookie_path") {
+		t.Fatalf("Authenticate() = %v, want an error about cookie_path", err)
+	}
+}
+
+// safeCookiePort is a control port offering SAFECOOKIE that knows serverCookie.
+func safeCookiePort(t *testing.T, cookiePath string, serverCookie []byte) *fakeControlPort {
+	serverNonce := []byte("server nonce, 32 bytes long.....")
+	var message []byte
+	return newFakeControlPort(t, func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "PROTOCOLINFO"):
+			return protocolInfo(`AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE="` + cookiePath + `"`)
+		case strings.HasPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "):
+			clientNonce, err := hex.DecodeString(strings.TrimPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "))
+			if err != nil {
+				return "513 Invalid base16 client nonce\r\n"
+			}
+			message = append(append(append([]byte{}, serverCookie...), clientNonce...), serverNonce...)
+			return "250 AUTHCHALLENGE SERVERHASH=" + hex.EncodeToString(mac(safeCookieServerKey, message)) +
+				" SERVERNONCE=" + hex.EncodeToString(serverNonce) + "\r\n"
+		case cmd == "AUTHENTICATE "+hex.EncodeToString(mac(safeCookieClientKey, message)):
+			return "250 OK\r\n"
+		}
+		return "515 Authentication failed\r\n"
+	})
+}
+
+func TestAuthenticateSafeCookie(t *testing.T) {
+	path, cookie := writeCookie(t)
+	port := safeCookiePort(t, path, cookie)
+	if err := port.dial().Authenticate("", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := port.sent("AUTHENTICATE " + hex.EncodeToString(cookie)); len(got) != 0 {
+		t.Error("the cookie itself was sent with SAFECOOKIE")
+	}
+}
+
+func TestAuthenticateSafeCookieWrongServerHash(t *testing.T) {
+	path, _ := writeCookie(t)
+	// A control port that doesn't know the cookie can't compute the server hash
+	port := safeCookiePort(t, path, []byte("not the cookie"))
+	err := port.dial().Authenticate("", "")
+	if err == nil || !strings.Contains(err.Error(), "does not know the auth cookie") {
+		t.Fatalf("Authenticate() = %v, want an error about the server hash", err)
+	}
+	if got := port.sent("AUTHENTICATE"); len(got) != 0 {
+		t.Errorf("sent %q to a control port that failed the challenge", got)
+	}
+}
+
+func TestAuthenticatePassword(t *testing.T) {
+	reply := func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "PROTOCOLINFO"):
+			return protocolInfo("AUTH METHODS=HASHEDPASSWORD")
+		case cmd == `AUTHENTICATE "p\"ss"`:
+			return "250 OK\r\n"
+		}
+		return "515 Authentication failed\r\n"
+	}
+
+	if err := newFakeControlPort(t, reply).dial().Authenticate(`p"ss`, ""); err != nil {
+		t.Errorf("with the password: %v", err)
+	}
+	if err := newFakeControlPort(t, reply).dial().Authenticate("wrong", ""); err == nil {
+		t.Error("a wrong password was accepted")
+	}
+
+	port := newFakeControlPort(t, reply)
+	if err := port.dial().Authenticate("", ""); err == nil || !strings.Contains(err.Error(), "needs a password") {
+		t.Errorf("without a password: %v, want an error asking for one", err)
+	}
+	if got := port.sent("AUTHENTICATE"); len(got) != 0 {
+		t.Errorf("sent %q without a password", got)
+	}
+}
+
+func TestNewCircuits(t *testing.T) {
+	port := newFakeControlPort(t, func(cmd string) string {
+		if cmd == "SIGNAL NEWNYM" {
+			return "250 OK\r\n"
+		}
+		return "510 Unrecognized command\r\n"
+	})
+	if err := port.dial().NewCircuits(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/sites"
 )
 
 var Version string = "v0.1.2"
@@ -21,14 +24,111 @@ type DeepScanResult struct {
 	RealName          *string   `json:"real_name,omitempty"`
 	// the IDK yet actions
 	NonDefinedActions []NonDefinedAction `json:"non_defined_actions,omitempty"`
+	// field (json name) -> FieldSourceSiteRule or FieldSourceGeneric
+	FieldSources map[string]string `json:"field_sources,omitempty"`
 }
 
+// Where a deep scan field came from
+const (
+	FieldSourceSiteRule = "site_rule" // a target in deepscan.json
+	FieldSourceGeneric  = "generic"   // meta tags, JSON-LD and other data any site can have
+)
+
+// FindingInfo holds extra information about how a site was found
+type FindingInfo struct {
+	// Set when AI verification failed and the finding was kept anyway (--ai-fail-policy open)
+	Unverified       bool   `json:"unverified,omitempty"`
+	UnverifiedReason string `json:"unverified_reason,omitempty"`
+	// How sure we are this is a real profile, from 0 to 1
+	Confidence float64 `json:"confidence"`
+	// "populated" or "placeholder" when the profile picture was checked with --ai-vision
+	VisionVerdict string `json:"vision_verdict,omitempty"`
+	// Set when the username was generated with --permute
+	Variant *VariantInfo `json:"variant,omitempty"`
+	// The links followed to reach this username with --pivot-depth, oldest first
+	Provenance []PivotStep `json:"provenance,omitempty"`
+	// The downloaded profile picture, with --save-images
+	Avatar *AvatarInfo `json:"avatar,omitempty"`
+	// Where the page is in the WARC file, with --archive
+	Archive *ArchiveRecord `json:"archive,omitempty"`
+	// The credentials.json profile the site was requested with
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// BlockedSite is a site that answered with a block, captcha or challenge page, even
+// after retrying through a new proxy or Tor circuit
+type BlockedSite struct {
+	URL      string `json:"url"`
+	Reason   string `json:"reason"`
+	Attempts int    `json:"attempts"`
+}
+
+// ArchiveRecord is the WARC record of a page saved with --archive. Hashes are hex encoded SHA-256.
+type ArchiveRecord struct {
+	RecordID      string `json:"record_id"`
+	Date          string `json:"date"` // when the response was received, RFC 3339 in UTC
+	URL           string `json:"url"`  // final URL after redirects
+	PayloadSHA256 string `json:"payload_sha256"`
+	BlockSHA256   string `json:"block_sha256"` // status line, headers and body
+}
+
+// ArchiveFile is the WARC file of a username, with --archive
+type ArchiveFile struct {
+	File    string `json:"file"` // relative to the report
+	SHA256  string `json:"sha256"`
+	Records int    `json:"records"`
+	// Where the file is written during the scan, it is moved next to the reports at the end
+	Path string `json:"-"`
+}
+
+// AvatarInfo is a profile picture saved with --save-images
+type AvatarInfo struct {
+	File   string `json:"file,omitempty"` // relative to the report, e.g. images/github.com.png
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	DHash  string `json:"dhash"`
+	// Other findings with a near-identical picture, a strong sign they are the same person
+	Matches []AvatarMatch `json:"matches,omitempty"`
+	Data    []byte        `json:"-"`
+	Format  string        `json:"-"`
+}
+
+// AvatarMatch is another finding with a near-identical profile picture
+type AvatarMatch struct {
+	Username string `json:"username"`
+	Site     string `json:"site"`
+	Distance int    `json:"distance"` // differing dHash bits, 0 is identical
+}
+
+// VariantInfo records how a permuted username was generated
+type VariantInfo struct {
+	Seed     string `json:"seed"`
+	Strategy string `json:"strategy"`
+}
+
+// PivotStep is one link followed with --pivot-depth
+type PivotStep struct {
+	From string `json:"from"` // username whose profile had the link
+	Site string `json:"site"` // domain of that profile
+	URL  string `json:"url"`  // the linked profile
+}
+
+// AI failure policies
+const (
+	AIFailOpen   = "open"   // keep the finding and mark it as unverified
+	AIFailClosed = "closed" // drop the finding
+)
+
 // Argument var
 var (
-	Verbose bool
-	AI      bool
-	Threads int
-	Silent  bool
+	Verbose      bool
+	AI           bool
+	AIVision     bool
+	AIFailPolicy string = AIFailOpen
+	Threads      int
+	Silent       bool
+	SaveImages   bool
+	Archive      bool
 )
 
 // IO vars
@@ -37,6 +137,9 @@ var (
 	ConfigJSONLocation    string
 	ConfigSourcesLocation string
 	PromptHTMLCheckFP     string
+	PromptVisionCheck     string
+	// SHA-256 or dHash (hex) of default and placeholder avatars, from avatar_blocklist.txt
+	AvatarBlocklist []string
 	// output vars
 	OutputFolder string
 	OutputTypes  []string
@@ -45,25 +148,63 @@ var (
 // Config variables
 var (
 	GeminiAPIKey string
+	AIProvider   string = "gemini"
+	AIModel      string = "gemini-2.0-flash-lite"
+	// model used for --ai-vision, defaults to AIModel
+	AIVisionModel string
+	// provider -> model -> limits, overrides the built-in defaults
+	AIRateLimits map[string]map[string]RateLimits
+	// How proxies are health checked, zero values use the defaults
+	ProxyCheck ProxyCheckConfig
+	// Where Tor listens and how circuits are rotated, with --tor
+	Tor TorConfig = TorConfig{SOCKS: "127.0.0.1:9050", Control: "127.0.0.1:9051", IsolateSites: true}
 )
 
 // Scanner vars
 var (
-	Usernames  []string
-	UserAgents []string
+	ScanStartedAt time.Time
+	Usernames     []string
+	// username -> how it was generated, only for --permute variants
+	UsernameVariants map[string]VariantInfo = make(map[string]VariantInfo)
 	// Options
 	Proxies []string
-	// Tor   bool
+	// How the next proxy is picked: round-robin, random or least-latency
+	ProxyStrategy string = "random"
+	// Failures in a row after which a proxy is dropped, 0 never drops one
+	ProxyMaxFailures int = 3
+	// How many hops of linked accounts to follow, 0 disables pivoting
+	PivotDepth int
+	// Most usernames pivoting may add to a scan
+	PivotMax int
+	// username -> links followed to reach it, only for pivoted usernames
+	UsernameProvenance map[string][]PivotStep = make(map[string][]PivotStep)
+	UseTor             bool
+	// Browser profile or family every site is requested as, "random" picks one per site
+	BrowserProfile string = "random"
+	// Names of the credentials.json profiles loaded for the scan
+	CredentialProfiles []string
+	// Narrows the sites of sources.txt that are scanned
+	SiteFilter sites.Filter
+	// Number of sites scanned, after SiteFilter
+	SitesScanned int
 )
 
 // result vars
 var (
 	FoundSites map[string]map[string]string = make(map[string]map[string]string)
 	FoundPFPs  map[string]map[string]string = make(map[string]map[string]string)
+	// username -> site -> info about the finding
+	FindingInfos map[string]map[string]FindingInfo = make(map[string]map[string]FindingInfo)
 	// AI result vars
 	AISiteSummaries map[string]string
 	AITotalSummary  string
 
+	// username -> site -> why it couldn't be checked
+	BlockedSites map[string]map[string]BlockedSite = make(map[string]map[string]BlockedSite)
+
+	// username -> WARC file, with --archive
+	ArchiveFiles map[string]*ArchiveFile = make(map[string]*ArchiveFile)
+
 	// Deep Scan related ones
 	DeepScanEnabled bool
 	DeepScanConfig  *map[string]DeepScanDomain
@@ -77,19 +218,67 @@ type DeepScanDomain struct {
 }
 
 type DeepScanTarget struct {
-	Name     string           `json:"name"`
-	Selector string           `json:"selector"`
-	Actions  []DeepScanAction `json:"actions"`
+	Name     string `json:"name"`
+	Selector string `json:"selector"`
+	// Path into JSON, in the body or in the elements matched by Selector, e.g. "props.user.bio"
+	JSONPath string `json:"json_path,omitempty"`
+	// Attribute to read instead of the element text, e.g. "href" or "content"
+	Attr string `json:"attr,omitempty"`
+	// Use every element matching Selector instead of only the first, the result is a list
+	All     bool             `json:"all,omitempty"`
+	Actions []DeepScanAction `json:"actions"`
 }
 
+// DeepScanAction is one step of a target's pipeline, applied in order
 type DeepScanAction struct {
 	Type  string `json:"type"`
 	Value string `json:"value"`
+	// Capture group for "regex", defaults to 1 (or the whole match if the pattern has no groups)
+	Group *int `json:"group,omitempty"`
+}
+
+// RateLimits describes the quota of a single AI model. A zero value means that dimension is unlimited.
+type RateLimits struct {
+	RequestsPerMinute int `json:"rpm"`
+	TokensPerMinute   int `json:"tpm"`
+	RequestsPerDay    int `json:"rpd"`
+}
+
+// ProxyCheckConfig is the "proxy_check" section of config.json
+type ProxyCheckConfig struct {
+	URL            string  `json:"url"`
+	ExpectedStatus int     `json:"expected_status"`
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	// Record every proxy's exit IP from IPURL (required, no default) and reject the ones that show our real IP
+	LeakCheck bool   `json:"leak_check"`
+	IPURL     string `json:"ip_url"`
+}
+
+// TorConfig is the "tor" section of config.json
+type TorConfig struct {
+	SOCKS   string `json:"socks"`   // host:port of the SOCKS port
+	Control string `json:"control"` // host:port of the control port, empty to never rotate
+	// Control port authentication, the cookie file Tor reports is used when both are empty
+	Password   string `json:"password"`
+	CookiePath string `json:"cookie_path"`
+	// Ask for new circuits every N requests through Tor, 0 only rotates when blocked
+	RotateEvery int `json:"rotate_every"`
+	// Give every site its own circuits, through a SOCKS username per site
+	IsolateSites bool `json:"isolate_sites"`
+}
+
+type aiConfig struct {
+	Provider    string                           `json:"provider"`
+	Model       string                           `json:"model"`
+	VisionModel string                           `json:"vision_model"`
+	Limits      map[string]map[string]RateLimits `json:"limits"`
 }
 
 type NonDefinedAction struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
+	// Every value, for targets that return a list
+	Values []string `json:"values,omitempty"`
 }
 
 func InitConfVars() {
@@ -107,6 +296,36 @@ func InitConfVars() {
 
 	GeminiAPIKey = keys["gemini"].(string)
 
+	if aiSection, ok := json["ai"]; ok {
+		var conf aiConfig
+		if err := remarshal(aiSection, &conf); err != nil {
+			printer.Error("Invalid \"ai\" section in config.json: %v", err)
+			os.Exit(1)
+		}
+		if conf.Provider != "" {
+			AIProvider = conf.Provider
+		}
+		if conf.Model != "" {
+			AIModel = conf.Model
+		}
+		AIVisionModel = conf.VisionModel
+		AIRateLimits = conf.Limits
+	}
+
+	if proxySection, ok := json["proxy_check"]; ok {
+		if err := remarshal(proxySection, &ProxyCheck); err != nil {
+			printer.Error("Invalid \"proxy_check\" section in config.json: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if torSection, ok := json["tor"]; ok {
+		if err := remarshal(torSection, &Tor); err != nil {
+			printer.Error("Invalid \"tor\" section in config.json: %v", err)
+			os.Exit(1)
+		}
+	}
+
 	HTMLCheckFilePath, err := getFilePath("html_check.txt")
 	if err != nil {
 		os.Exit(1)
@@ -116,17 +335,32 @@ func InitConfVars() {
 		os.Exit(1)
 	}
 
+	// Optional, only needed for --ai-vision
+	VisionCheckFilePath, err := getFilePath("vision_check.txt")
+	if err == nil && VisionCheckFilePath != "" {
+		PromptVisionCheck, _ = getFileContent(VisionCheckFilePath)
+	}
+
+	// Optional, only needed for --save-images
+	blocklistPath, err := getFilePath("avatar_blocklist.txt")
+	if err == nil && blocklistPath != "" {
+		content, _ := getFileContent(blocklistPath)
+		AvatarBlocklist = nil
+		for _, line := range strings.Split(content, "\n") {
+			if line, _, _ = strings.Cut(line, "#"); strings.TrimSpace(line) != "" {
+				AvatarBlocklist = append(AvatarBlocklist, strings.ToLower(strings.TrimSpace(line)))
+			}
+		}
+	}
+
 	deepScanConfigLocation, err := getFilePath("deepscan.json")
 	if err == nil {
 		_, err = LoadAndStringifyJSON(deepScanConfigLocation, &DeepScanConfig)
 		if err != nil {
-			// Handle error, maybe log it and disable deep scanning
-			printer.Error("Could not import deepscan.json, continuing without deep scanning.")
-			printer.Info("If you made any changes to deepscan.json, please ensure your changes are valid.")
-			DeepScanEnabled = false
+			printer.Error("Could not import deepscan.json (%v), deep scans will only use generic extraction.", err)
+			printer.Info("Run 'argus deepscan validate' to find the problem.")
+			DeepScanConfig = nil
 		}
-	} else {
-		DeepScanEnabled = false
 	}
 
 }
@@ -146,6 +380,15 @@ func LoadAndStringifyJSON(path string, v any) (string, error) {
 	return string(b), nil
 }
 
+// remarshal converts a generic JSON value (as decoded into map[string]any) into a typed struct.
+func remarshal(in any, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
 func getFilePath(name string) (string, error) {
 	var FilePath string
 	err := filepath.WalkDir(ConfigDir, func(path string, d os.DirEntry, err error) error {
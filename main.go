@@ -2,16 +2,25 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v3"
 
+	"github.com/KillAllChickens/argus/internal/browser"
 	"github.com/KillAllChickens/argus/internal/config"
 	"github.com/KillAllChickens/argus/internal/helpers"
 	"github.com/KillAllChickens/argus/internal/io"
+	"github.com/KillAllChickens/argus/internal/output"
+	"github.com/KillAllChickens/argus/internal/permute"
 	"github.com/KillAllChickens/argus/internal/printer"
+	"github.com/KillAllChickens/argus/internal/proxy"
 	"github.com/KillAllChickens/argus/internal/scanner"
+	"github.com/KillAllChickens/argus/internal/sites"
 	"github.com/KillAllChickens/argus/internal/vars"
 	"github.com/skratchdot/open-golang/open"
 )
@@ -83,6 +92,15 @@ func main() {
 						Name:  "ai",
 						Usage: "Use AI to eliminate false positives. (Increases scan time)",
 					},
+					&cli.BoolFlag{
+						Name:  "ai-vision",
+						Usage: "Also send the profile picture to a vision model to spot default or placeholder profiles. (Requires --ai)",
+					},
+					&cli.StringFlag{
+						Name:  "ai-fail-policy",
+						Usage: "What to do with a finding when AI verification fails: \"open\" keeps it marked as unverified, \"closed\" drops it",
+						Value: vars.AIFailOpen,
+					},
 					&cli.StringFlag{
 						Name:        "username-list",
 						Aliases:     []string{"u"},
@@ -109,11 +127,30 @@ func main() {
 						DefaultText: "",
 						Usage:       "List of proxied to use, one per line.",
 					},
-					&cli.BoolFlag{Name: "tor", Usage: "Use Tor for scanning"},
+					&cli.BoolFlag{Name: "tor", Usage: "Use Tor for scanning, rotating circuits through its control port (see the \"tor\" section of config.json)", Destination: &vars.UseTor},
+					&cli.IntFlag{Name: "tor-rotate-every", Usage: "Ask Tor for new circuits every N requests, 0 only when blocked (overrides config.json)"},
+					&cli.StringFlag{Name: "proxy-strategy", Value: string(proxy.Random), Usage: "How the proxy of each request is picked: " + proxyStrategyNames(), Destination: &vars.ProxyStrategy},
+					&cli.IntFlag{Name: "proxy-max-failures", Value: 3, Usage: "Drop a proxy after this many failed requests in a row, 0 never drops one", Destination: &vars.ProxyMaxFailures},
+
+					&cli.StringFlag{Name: "browser", Value: "random", Usage: "Browser whose headers every request is sent with, pinned for the whole run: random (a new one per site), " + strings.Join(browser.Names(), ", "), Destination: &vars.BrowserProfile},
+					&cli.StringFlag{Name: "include-tags", Usage: "Comma separated categories or tags, only scan the sites with one of them (e.g. dev,gaming)"},
+					&cli.StringFlag{Name: "exclude-tags", Usage: "Comma separated categories or tags, skip the sites with one of them (e.g. adult)"},
+					&cli.StringFlag{Name: "sites", Usage: "Comma separated domains or URLs of sources.txt, only scan these sites (e.g. github.com,gitlab.com)"},
+					&cli.StringFlag{Name: "exclude-sites", Usage: "Comma separated domains or URLs of sources.txt, skip these sites"},
 
 					&cli.BoolFlag{Name: "silent", Aliases: []string{"s"}, Usage: "Disable \"Scan Complete\" notifications.", Destination: &vars.Silent},
 
+					&cli.BoolFlag{Name: "permute", Usage: "Also scan variations of each username (separators, leetspeak, digits, affixes...)"},
+					&cli.IntFlag{Name: "permute-max", Usage: "Maximum amount of variations per username", Value: 25},
+					&cli.StringFlag{Name: "permute-strategies", Usage: "Comma separated permutation strategies: " + strategyNames(), Value: "all"},
+					&cli.StringFlag{Name: "first-name", Usage: "First name to generate usernames from (implies --permute)"},
+					&cli.StringFlag{Name: "last-name", Usage: "Last name to generate usernames from (implies --permute)"},
+
 					&cli.BoolFlag{Name: "deep", Aliases: []string{"d"}, Usage: "Run a Deep Scan, will try to collect more information", Destination: &vars.DeepScanEnabled},
+					&cli.BoolFlag{Name: "save-images", Usage: "Download profile pictures into the output folder and compare them across sites", Destination: &vars.SaveImages},
+					&cli.BoolFlag{Name: "archive", Usage: "Save the exact HTTP response of every found site to a WARC file per username", Destination: &vars.Archive},
+					&cli.IntFlag{Name: "pivot-depth", Usage: "Also scan accounts linked from found profiles, following links up to N hops away (Requires --deep)", Destination: &vars.PivotDepth},
+					&cli.IntFlag{Name: "pivot-max", Value: 50, Usage: "Most usernames --pivot-depth may add to the scan", Destination: &vars.PivotMax},
 
 					// Output types
 					&cli.BoolFlag{Name: "html", Usage: "Output as HTML"},
@@ -134,6 +171,18 @@ func main() {
 				Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
 
 					vars.AI = cmd.Bool("ai")
+					vars.AIVision = cmd.Bool("ai-vision")
+					if vars.AIVision && !vars.AI {
+						printer.Error("--ai-vision requires --ai.")
+						os.Exit(1)
+					}
+					switch cmd.String("ai-fail-policy") {
+					case vars.AIFailOpen, vars.AIFailClosed:
+						vars.AIFailPolicy = cmd.String("ai-fail-policy")
+					default:
+						printer.Error("--ai-fail-policy must be \"%s\" or \"%s\"", vars.AIFailOpen, vars.AIFailClosed)
+						os.Exit(1)
+					}
 					if cmd.String("output") == "" {
 						vars.OutputFolder = "./results/"
 					} else {
@@ -171,16 +220,34 @@ func main() {
 						vars.Proxies = append(vars.Proxies, cmd.String("proxy"))
 					}
 
-					if cmd.Bool("tor") {
-						vars.Proxies = append(vars.Proxies, "socks5://127.0.0.1:9050")
-					}
-
 					if cmd.String("proxy-list") != "" {
 						vars.Proxies, _ = io.NewlineSeperatedFileToArray(cmd.String("proxy-list"))
 					}
 
+					if !slices.Contains(proxy.Strategies, proxy.Strategy(vars.ProxyStrategy)) {
+						printer.Error("--proxy-strategy must be one of: %s", proxyStrategyNames())
+						os.Exit(1)
+					}
+
+					if len(browser.Lookup(vars.BrowserProfile)) == 0 {
+						printer.Error("--browser must be random or one of: %s", strings.Join(browser.Names(), ", "))
+						os.Exit(1)
+					}
+
+					if vars.PivotDepth > 0 && !vars.DeepScanEnabled {
+						printer.Error("--pivot-depth requires --deep.")
+						os.Exit(1)
+					}
+
 					vars.Threads = cmd.Int("threads")
 
+					vars.SiteFilter = sites.Filter{
+						IncludeTags:  splitList(cmd.String("include-tags")),
+						ExcludeTags:  splitList(cmd.String("exclude-tags")),
+						Sites:        splitList(cmd.String("sites")),
+						ExcludeSites: splitList(cmd.String("exclude-sites")),
+					}
+
 					return ctx, nil
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
@@ -195,7 +262,8 @@ func main() {
 					} else {
 						usernames_list = usernames
 					}
-					if len(usernames_list) == 0 {
+					hasName := cmd.String("first-name") != "" || cmd.String("last-name") != ""
+					if len(usernames_list) == 0 && !hasName {
 						printer.Error("At least one username is required!")
 						return cli.ShowSubcommandHelp(cmd)
 					}
@@ -213,9 +281,50 @@ func main() {
 						}
 					}
 
+					if cmd.Bool("permute") || hasName {
+						strategies, err := permute.ParseStrategies(cmd.String("permute-strategies"))
+						if err != nil {
+							printer.Error("%v", err)
+							return nil
+						}
+						opts := permute.Options{Strategies: strategies, Max: cmd.Int("permute-max")}
+
+						var seeds []permute.Seed
+						var permuted []string
+						typed := usernames_list
+						if cmd.Bool("permute") {
+							for _, username := range usernames_list {
+								seeds = append(seeds, permute.Seed{Handle: username})
+							}
+						} else {
+							permuted = usernames_list // only the name is permuted
+						}
+						if hasName {
+							seeds = append(seeds, permute.Seed{First: cmd.String("first-name"), Last: cmd.String("last-name")})
+						}
+
+						for _, seed := range seeds {
+							for _, variant := range permute.Generate(seed, opts) {
+								if slices.Contains(permuted, variant.Username) {
+									continue
+								}
+								permuted = append(permuted, variant.Username)
+								// Usernames that were typed in are not permutation hits
+								if variant.Strategy != permute.Original && !slices.Contains(typed, variant.Username) {
+									vars.UsernameVariants[variant.Username] = vars.VariantInfo{Seed: variant.Seed, Strategy: string(variant.Strategy)}
+								}
+							}
+						}
+						printer.Info("Generated %d usernames to scan", len(permuted))
+						usernames_list = permuted
+					}
+
 					vars.Usernames = usernames_list
 
 					scanner.Init(cmd.String("config-path"))
+					if cmd.IsSet("tor-rotate-every") {
+						vars.Tor.RotateEvery = cmd.Int("tor-rotate-every")
+					}
 
 					if vars.AI {
 						if vars.GeminiAPIKey == "" {
@@ -223,12 +332,225 @@ func main() {
 							_ = cli.ShowAppHelp(cmd) // Use _ to ignore the error
 							return nil
 						}
+						if vars.AIVision && vars.PromptVisionCheck == "" {
+							printer.Error("--ai-vision needs prompts/vision_check.txt in your config directory. Run '%s config-dir' and copy it from the repository's config folder.", cmd.Root().Name)
+							return nil
+						}
 					}
-					scanner.StartScan(vars.Usernames)
+					scanner.StartScan(ctx, vars.Usernames)
 
 					return nil
 				},
 			},
+			{
+				Name:      "permute",
+				Usage:     "Print variations of a username, or of a first and last name.",
+				ArgsUsage: "[username]",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "max", Usage: "Maximum amount of variations", Value: 50},
+					&cli.StringFlag{Name: "strategies", Usage: "Comma separated permutation strategies: " + strategyNames(), Value: "all"},
+					&cli.StringFlag{Name: "first-name", Usage: "First name to generate usernames from"},
+					&cli.StringFlag{Name: "last-name", Usage: "Last name to generate usernames from"},
+					&cli.BoolFlag{Name: "show-strategy", Usage: "Print the strategy next to each variation"},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					seed := permute.Seed{Handle: cmd.Args().First(), First: cmd.String("first-name"), Last: cmd.String("last-name")}
+					if seed.String() == "" {
+						printer.Error("A username or a --first-name/--last-name is required!")
+						return cli.ShowSubcommandHelp(cmd)
+					}
+					strategies, err := permute.ParseStrategies(cmd.String("strategies"))
+					if err != nil {
+						return err
+					}
+					for _, variant := range permute.Generate(seed, permute.Options{Strategies: strategies, Max: cmd.Int("max")}) {
+						if cmd.Bool("show-strategy") {
+							fmt.Printf("%s\t%s\n", variant.Username, variant.Strategy)
+						} else {
+							fmt.Println(variant.Username)
+						}
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "deepscan",
+				Usage: "Test and validate deep scan rules (deepscan.json).",
+				Commands: []*cli.Command{
+					{
+						Name:      "test",
+						Usage:     "Run the rules of a domain against a page and show what every target matched.",
+						ArgsUsage: "<domain>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Usage: "Saved HTML page to test against"},
+							&cli.StringFlag{Name: "url", Usage: "URL of a profile to fetch and test against"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Args().Len() != 1 {
+								printer.Error("A domain is required!")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							scanner.Init(cmd.String("config-path"))
+							return scanner.TestDeepScan(ctx, cmd.Args().First(), cmd.String("file"), cmd.String("url"))
+						},
+					},
+					{
+						Name:      "validate",
+						Usage:     "Check deepscan.json for mistakes, with their line and column.",
+						ArgsUsage: "[file]",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							path := cmd.Args().First()
+							if path == "" {
+								io.InitPaths(cmd.String("config-path"))
+								var err error
+								path, err = io.GetFilePath("deepscan.json")
+								if err != nil {
+									return err
+								}
+								if path == "" {
+									return fmt.Errorf("deepscan.json not found in %s", vars.ConfigDir)
+								}
+							}
+							return scanner.ValidateDeepScanFile(path)
+						},
+					},
+				},
+			},
+			{
+				Name:  "sites",
+				Usage: "List and edit the sites of sources.txt.",
+				Commands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "List the sites, enabled and disabled.",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "category", Usage: "Only list the sites of this category"},
+							&cli.StringFlag{Name: "tag", Usage: "Only list the sites with this tag"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							io.InitPaths(cmd.String("config-path"))
+							return scanner.ListSites(cmd.String("category"), cmd.String("tag"))
+						},
+					},
+					{
+						Name:      "add",
+						Usage:     "Validate a site definition, probe it with a known username and add it.",
+						ArgsUsage: "<url> [key=value ...]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Usage: "Username known to exist on the site, to probe it with"},
+							&cli.BoolFlag{Name: "no-probe", Usage: "Add the site without probing it"},
+							&cli.BoolFlag{Name: "force", Usage: "Add the site even if the probe fails"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Args().Len() == 0 {
+								printer.Error("A site URL is required!")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							username := cmd.String("username")
+							if username == "" && !cmd.Bool("no-probe") {
+								printer.Error("A username known to exist on the site is required to probe it (--username), or --no-probe")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							scanner.Init(cmd.String("config-path"))
+							return scanner.AddSite(ctx, strings.Join(cmd.Args().Slice(), " "), username, cmd.Bool("force"))
+						},
+					},
+					{
+						Name:      "remove",
+						Usage:     "Remove a site, by URL or domain.",
+						ArgsUsage: "<url|domain>",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Args().Len() != 1 {
+								printer.Error("A site is required!")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							io.InitPaths(cmd.String("config-path"))
+							return scanner.RemoveSite(cmd.Args().First())
+						},
+					},
+					{
+						Name:      "enable",
+						Usage:     "Enable a disabled site, by URL or domain.",
+						ArgsUsage: "<url|domain>",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Args().Len() != 1 {
+								printer.Error("A site is required!")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							io.InitPaths(cmd.String("config-path"))
+							return scanner.SetSiteEnabled(cmd.Args().First(), true)
+						},
+					},
+					{
+						Name:      "disable",
+						Usage:     "Disable a site without removing it, by URL or domain.",
+						ArgsUsage: "<url|domain>",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Args().Len() != 1 {
+								printer.Error("A site is required!")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							io.InitPaths(cmd.String("config-path"))
+							return scanner.SetSiteEnabled(cmd.Args().First(), false)
+						},
+					},
+					{
+						Name:      "check",
+						Usage:     "Look up the known and missing username of each site to spot broken detection, and write a JSON health report.",
+						ArgsUsage: "[url|domain ...]",
+						Flags: []cli.Flag{
+							&cli.IntFlag{Name: "threads", Aliases: []string{"t"}, Value: 10, Usage: "Amount of sites checked at once"},
+							&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Where to write the health report, defaults to ./results/sites-health-<time>.json"},
+							&cli.BoolFlag{Name: "strict", Usage: "Exit with an error if a site is not ok, untested sites included, for CI"},
+							&cli.StringFlag{Name: "record", Usage: "Save the requests and responses of every site to this directory, one fixture file per site"},
+							&cli.StringFlag{Name: "replay", Usage: "Answer every request from the fixtures in this directory, without network access"},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							scanner.Init(cmd.String("config-path"))
+							outPath := cmd.String("output")
+							if outPath == "" {
+								outPath = filepath.Join("results", "sites-health-"+time.Now().Format("20060102-150405")+".json")
+							}
+							if cmd.String("record") != "" && cmd.String("replay") != "" {
+								printer.Error("--record and --replay can't be used together")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							opts := scanner.ProberOptions{Record: cmd.String("record"), Replay: cmd.String("replay")}
+							return scanner.CheckSites(ctx, cmd.Args().Slice(), cmd.Int("threads"), outPath, cmd.Bool("strict"), opts)
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "Merge the sites of another sources file, skipping the ones already there.",
+						ArgsUsage: "<file>",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if cmd.Args().Len() != 1 {
+								printer.Error("A file is required!")
+								return cli.ShowSubcommandHelp(cmd)
+							}
+							io.InitPaths(cmd.String("config-path"))
+							return scanner.ImportSites(cmd.Args().First())
+						},
+					},
+				},
+			},
+			{
+				Name:      "verify",
+				Usage:     "Check the signature of a scan manifest and the hashes of the files it lists.",
+				ArgsUsage: "<manifest>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "key", Usage: "Trusted ed25519 public key (PEM) the manifest must be signed with, defaults to manifest_key.pem in the config directory"},
+					&cli.BoolFlag{Name: "require-signature", Usage: "Fail on an unsigned manifest even without a trusted key"},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					if cmd.Args().Len() != 1 {
+						printer.Error("A manifest is required!")
+						return cli.ShowSubcommandHelp(cmd)
+					}
+					io.InitPaths(cmd.String("config-path"))
+					return output.VerifyManifest(cmd.Args().First(), cmd.String("key"), cmd.Bool("require-signature"))
+				},
+			},
 			{
 				Name: "config-dir",
 				Action: func(ctx context.Context, cmd *cli.Command) error {
@@ -268,3 +590,30 @@ func main() {
 	err := cmd.Run(context.Background(), os.Args)
 	helpers.HandleErr(err)
 }
+
+func strategyNames() string {
+	var names []string
+	for _, strategy := range permute.AllStrategies {
+		names = append(names, string(strategy))
+	}
+	return strings.Join(names, ", ")
+}
+
+func proxyStrategyNames() string {
+	var names []string
+	for _, strategy := range proxy.Strategies {
+		names = append(names, string(strategy))
+	}
+	return strings.Join(names, ", ")
+}
+
+// splitList splits a comma separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}